@@ -0,0 +1,138 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"io/fs"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/adamkpickering/jenny/internal/content"
+	"github.com/adamkpickering/jenny/internal/memfs"
+	"github.com/adamkpickering/jenny/internal/redirects"
+)
+
+// The values of AliasRedirects in configuration.yaml, other than "" (which
+// means that no file is written).
+const (
+	netlifyRedirects = redirects.FileName
+	apacheRedirects  = ".htaccess"
+)
+
+// aliasPageTemplate is the page that is written at the path of an alias.
+// It redirects browsers to the page, and tells search engines to index
+// the page rather than the alias.
+const aliasPageTemplate = `<!DOCTYPE html>
+<html>
+<head>
+<title>%[1]s</title>
+<link rel="canonical" href="%[1]s">
+<meta name="robots" content="noindex">
+<meta charset="utf-8">
+<meta http-equiv="refresh" content="0; url=%[1]s">
+</head>
+</html>
+`
+
+// alias is a path that redirects to a page.
+type alias struct {
+	// The path of the alias page relative to the output directory.
+	sitePath string
+	page     *content.ContentFile
+}
+
+// writeAliases writes a page to site at the path of each alias of pages
+// that redirects to that page. outputSources maps the path of each file
+// that is already in site to the source file it was built from; aliases
+// must not collide with any of them. If redirectsFormat is set, a file of
+// that format that contains a redirect for each alias is written too.
+func writeAliases(site *memfs.FS, pages []*content.ContentFile, outputSources map[string]string, redirectsFormat string) error {
+	aliases := []alias{}
+	aliasSources := map[string]string{}
+	for _, contentFile := range pages {
+		aliasPaths, err := contentFile.AliasPaths()
+		if err != nil {
+			return &sourceFileError{
+				SourcePath: contentFile.SourcePath,
+				Err:        fmt.Errorf("invalid alias in %s: %w", contentFile.SourcePath, err),
+			}
+		}
+		for _, aliasPath := range aliasPaths {
+			otherSourcePath, ok := outputSources[aliasPath]
+			if !ok {
+				otherSourcePath, ok = aliasSources[aliasPath]
+			}
+			if ok {
+				return &sourceFileError{
+					SourcePath: contentFile.SourcePath,
+					Err:        fmt.Errorf("alias %s of %s collides with %s", content.URLPath(aliasPath), contentFile.SourcePath, otherSourcePath),
+				}
+			}
+			aliasSources[aliasPath] = contentFile.SourcePath
+			aliases = append(aliases, alias{sitePath: aliasPath, page: contentFile})
+		}
+	}
+
+	for _, alias := range aliases {
+		target := html.EscapeString(pageURLPath(alias.page))
+		aliasPage := fmt.Sprintf(aliasPageTemplate, target)
+		if err := site.WriteFile(alias.sitePath, []byte(aliasPage)); err != nil {
+			return &sourceFileError{
+				SourcePath: alias.page.SourcePath,
+				Err:        fmt.Errorf("failed to write alias page %s: %w", alias.sitePath, err),
+			}
+		}
+	}
+
+	if redirectsFormat == "" || len(aliases) == 0 {
+		return nil
+	}
+	return writeRedirectsFile(site, aliases, redirectsFormat)
+}
+
+// writeRedirectsFile writes a file of the given format that redirects each
+// of aliases to its page. If the site already has such a file, the
+// redirects are added to the end of it.
+func writeRedirectsFile(site *memfs.FS, aliases []alias, redirectsFormat string) error {
+	contents, err := site.ReadFile(redirectsFormat)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to read %s: %w", redirectsFormat, err)
+	}
+	builder := &strings.Builder{}
+	builder.Write(contents)
+	if len(contents) > 0 && !strings.HasSuffix(string(contents), "\n") {
+		builder.WriteString("\n")
+	}
+
+	builder.WriteString("# aliases\n")
+	for _, alias := range aliases {
+		from := content.URLPath(alias.sitePath)
+		to := pageURLPath(alias.page)
+		switch redirectsFormat {
+		case netlifyRedirects:
+			fmt.Fprintf(builder, "%s %s 301\n", from, to)
+		case apacheRedirects:
+			// Match with and without the trailing slash, like static
+			// hosts do.
+			pattern := "^" + regexp.QuoteMeta(strings.TrimSuffix(from, "/")) + "/?$"
+			if !strings.HasSuffix(from, "/") {
+				pattern = "^" + regexp.QuoteMeta(from) + "$"
+			}
+			fmt.Fprintf(builder, "RedirectMatch 301 %s %s\n", pattern, to)
+		default:
+			return fmt.Errorf("unknown AliasRedirects %q: must be %q or %q", redirectsFormat, netlifyRedirects, apacheRedirects)
+		}
+	}
+
+	if err := site.WriteFile(redirectsFormat, []byte(builder.String())); err != nil {
+		return fmt.Errorf("failed to write %s: %w", redirectsFormat, err)
+	}
+	return nil
+}
+
+// pageURLPath returns the path of the URL of contentFile.
+func pageURLPath(contentFile *content.ContentFile) string {
+	return content.URLPath(filepath.ToSlash(contentFile.Path))
+}
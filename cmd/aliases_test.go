@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/adamkpickering/jenny/internal/content"
+	"github.com/adamkpickering/jenny/internal/memfs"
+)
+
+func TestWriteAliases(t *testing.T) {
+	newPages := func() []*content.ContentFile {
+		return []*content.ContentFile{
+			{
+				Path:       "/posts/new-name.html",
+				SourcePath: "input/posts/new-name.md",
+				Metadata:   content.ContentMetadata{Aliases: []string{"/old-name"}},
+			},
+			{
+				Path:       "/about.html",
+				SourcePath: "input/about.md",
+			},
+		}
+	}
+	outputSources := map[string]string{
+		"posts/new-name.html": "input/posts/new-name.md",
+		"about.html":          "input/about.md",
+	}
+
+	t.Run("should write page that redirects to page", func(t *testing.T) {
+		site := memfs.New()
+		if err := writeAliases(site, newPages(), outputSources, ""); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		contents, err := site.ReadFile("old-name/index.html")
+		if err != nil {
+			t.Fatalf("failed to read alias page: %s", err)
+		}
+		for _, expected := range []string{
+			`<link rel="canonical" href="/posts/new-name.html">`,
+			`<meta http-equiv="refresh" content="0; url=/posts/new-name.html">`,
+		} {
+			if !strings.Contains(string(contents), expected) {
+				t.Errorf("alias page %q does not contain %q", contents, expected)
+			}
+		}
+		if site.Exists("_redirects") {
+			t.Errorf("_redirects was written but should not have been")
+		}
+	})
+
+	t.Run("should return error when alias collides with page", func(t *testing.T) {
+		pages := newPages()
+		pages[0].Metadata.Aliases = []string{"/about.html"}
+		err := writeAliases(memfs.New(), pages, outputSources, "")
+		if err == nil {
+			t.Fatalf("did not get error when we should have")
+		}
+		expected := "alias /about.html of input/posts/new-name.md collides with input/about.md"
+		if err.Error() != expected {
+			t.Errorf("got error %q but expected %q", err, expected)
+		}
+	})
+
+	t.Run("should return error when aliases collide", func(t *testing.T) {
+		pages := newPages()
+		pages[1].Metadata.Aliases = []string{"/old-name/"}
+		if err := writeAliases(memfs.New(), pages, outputSources, ""); err == nil {
+			t.Errorf("did not get error when we should have")
+		}
+	})
+
+	t.Run("should add aliases to _redirects", func(t *testing.T) {
+		site := memfs.New()
+		if err := site.WriteFile("_redirects", []byte("/a /b 302")); err != nil {
+			t.Fatalf("failed to write _redirects: %s", err)
+		}
+		if err := writeAliases(site, newPages(), outputSources, "_redirects"); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		contents, err := site.ReadFile("_redirects")
+		if err != nil {
+			t.Fatalf("failed to read _redirects: %s", err)
+		}
+		expected := "/a /b 302\n# aliases\n/old-name/ /posts/new-name.html 301\n"
+		if string(contents) != expected {
+			t.Errorf("got %q but expected %q", contents, expected)
+		}
+	})
+
+	t.Run("should write .htaccess", func(t *testing.T) {
+		site := memfs.New()
+		if err := writeAliases(site, newPages(), outputSources, ".htaccess"); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		contents, err := site.ReadFile(".htaccess")
+		if err != nil {
+			t.Fatalf("failed to read .htaccess: %s", err)
+		}
+		expected := "# aliases\nRedirectMatch 301 ^/old-name/?$ /posts/new-name.html\n"
+		if string(contents) != expected {
+			t.Errorf("got %q but expected %q", contents, expected)
+		}
+	})
+}
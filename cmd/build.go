@@ -3,18 +3,23 @@ package cmd
 import (
 	"bytes"
 	"fmt"
-	"io"
 	"io/fs"
 	"os"
+	"path"
 	"path/filepath"
 	"strings"
 	"text/template"
 	"time"
 
+	"github.com/adamkpickering/jenny/internal/asset"
 	"github.com/adamkpickering/jenny/internal/config"
 	"github.com/adamkpickering/jenny/internal/content"
+	"github.com/adamkpickering/jenny/internal/data"
+	"github.com/adamkpickering/jenny/internal/ignore"
+	"github.com/adamkpickering/jenny/internal/images"
+	"github.com/adamkpickering/jenny/internal/memfs"
+	"github.com/adamkpickering/jenny/internal/render"
 	"github.com/spf13/cobra"
-	"github.com/yuin/goldmark"
 )
 
 // TemplateData is the data that gets passed when building a template.
@@ -23,10 +28,14 @@ type TemplateData struct {
 	Page *content.ContentFile `yaml:"Page"`
 	// A slice of all content pages in this website.
 	Pages []*content.ContentFile `yaml:"Pages"`
+	// The top-level sections of this website.
+	Sections []*content.Section `yaml:"Sections"`
 	// Any extra data that doesn't have anything to do with pages that we want
 	// to make available in templates.
 	Computed Computed          `yaml:"Computed"`
 	Config   config.ConfigYaml `yaml:"Config"`
+	// The contents of the files in the data directory.
+	Data map[string]any `yaml:"Data"`
 }
 
 type Computed struct {
@@ -44,72 +53,243 @@ var buildCmd = &cobra.Command{
 }
 
 func runBuild(cmd *cobra.Command, args []string) error {
-	return build()
+	site, summary, err := build(true)
+	if err != nil {
+		return err
+	}
+	if err := writeOutput(site, configYaml.Output); err != nil {
+		return err
+	}
+	fmt.Println(summary)
+	return nil
 }
 
-func build() error {
-	templatesGlob := filepath.Join(configYaml.Templates, "*.gotmpl")
-	templates, err := template.ParseGlob(templatesGlob)
+// build builds the site in memory. The returned filesystem contains what
+// should be in the output directory. If minifyFiles is true, the files of
+// the media types in the Minify config field are minified.
+func build(minifyFiles bool) (*memfs.FS, buildSummary, error) {
+	var fileMinifier *minifier
+	if minifyFiles {
+		fileMinifier = &minifier{mediaTypes: configYaml.Minify}
+	}
+	ignored, err := ignoreMatcher(configYaml)
 	if err != nil {
-		return fmt.Errorf("failed to parse templates: %w", err)
+		return nil, buildSummary{}, err
 	}
-
-	nonMdFiles, templateData, err := gatherFileInfo(configYaml)
+	assets, templateData, err := gatherFileInfo(configYaml, ignored, fileMinifier)
 	if err != nil {
-		return fmt.Errorf("failed to gather info on input files: %w", err)
+		return nil, buildSummary{}, fmt.Errorf("failed to gather info on input files: %w", err)
 	}
 
-	// wipe output directory
-	if err := os.RemoveAll(configYaml.Output); err != nil {
-		return fmt.Errorf("failed to wipe output dir: %w", err)
+	processor := images.New(filepath.Join(configYaml.Cache, "images"), configYaml.Images.Quality, configYaml.Images.KeepExif)
+	funcs := imageFuncs(processor, assets, configYaml.Images)
+	funcs["asset"] = assetFunc(assets)
+	templates, err := parseTemplates(configYaml.Templates, ignored, funcs)
+	if err != nil {
+		return nil, buildSummary{}, fmt.Errorf("failed to parse templates: %w", err)
 	}
-	if err := os.MkdirAll(configYaml.Output, 0o755); err != nil {
-		return fmt.Errorf("failed to ensure output dir exists: %w", err)
+
+	// Convert all markdown before executing any templates, so that
+	// templates have access to the content of every page.
+	if err := renderPages(configYaml, templateData.Pages, ignored, imageResolver(processor, assets, configYaml.Images)); err != nil {
+		return nil, buildSummary{}, err
 	}
 
+	site := memfs.New()
+	// maps the path of each file in site to the input file it came from
+	outputSources := map[string]string{}
+
 	// copy over non-markdown files
-	for _, nonMdFile := range nonMdFiles {
-		inputPath := filepath.Join(configYaml.Input, nonMdFile)
-		outputPath := filepath.Join(configYaml.Output, nonMdFile)
-		parentDir := filepath.Dir(outputPath)
-		if err := os.MkdirAll(parentDir, 0o755); err != nil {
-			return fmt.Errorf("failed to create parent dir %s: %w", parentDir, err)
-		}
-		if err := copyFile(outputPath, inputPath); err != nil {
-			return fmt.Errorf("failed to copy %s to %s: %w", inputPath, outputPath, err)
+	for _, fileAsset := range assets {
+		if err := site.WriteFile(fileAsset.SitePath, fileAsset.Contents); err != nil {
+			return nil, buildSummary{}, fmt.Errorf("failed to copy %s: %w", fileAsset.SourcePath, err)
 		}
+		outputSources[fileAsset.SitePath] = fileAsset.SourcePath
 	}
 
 	// build markdown files
 	for _, contentFile := range templateData.Pages {
-		outputPath := filepath.Join(configYaml.Output, contentFile.Path)
-		parentDir := filepath.Dir(outputPath)
-		if err := os.MkdirAll(parentDir, 0o755); err != nil {
-			return fmt.Errorf("failed to create parent dir %s: %w", parentDir, err)
+		templateData.Page = contentFile
+
+		builtPage := &bytes.Buffer{}
+		if err := templates.ExecuteTemplate(builtPage, contentFile.Metadata.TemplateName, &templateData); err != nil {
+			return nil, buildSummary{}, &sourceFileError{
+				SourcePath: contentFile.SourcePath,
+				Err:        fmt.Errorf("failed to execute %s for %s: %w", contentFile.Metadata.TemplateName, contentFile.Path, err),
+			}
 		}
+		outputPath := strings.TrimPrefix(filepath.ToSlash(contentFile.Path), "/")
+		minifiedPage, err := fileMinifier.minify(outputPath, builtPage.Bytes())
+		if err != nil {
+			return nil, buildSummary{}, &sourceFileError{SourcePath: contentFile.SourcePath, Err: err}
+		}
+		if err := site.WriteFile(outputPath, minifiedPage); err != nil {
+			return nil, buildSummary{}, fmt.Errorf("failed to write %s: %w", contentFile.Path, err)
+		}
+		outputSources[outputPath] = contentFile.SourcePath
+	}
 
-		builtContent := &bytes.Buffer{}
-		if err := goldmark.Convert([]byte(contentFile.RawContent), builtContent); err != nil {
-			return fmt.Errorf("failed to build %s: %w", contentFile.Path, err)
+	// write the images that markdown and templates processed
+	processedImages := processor.Processed()
+	for _, processedImage := range processedImages {
+		if otherSourcePath, ok := outputSources[processedImage.SitePath]; ok {
+			return nil, buildSummary{}, fmt.Errorf("%s and an image processed from %s would both be built to %s", otherSourcePath, processedImage.SourcePath, processedImage.SitePath)
 		}
-		contentFile.Content = builtContent.String()
-		templateData.Page = contentFile
+		if err := site.WriteFile(processedImage.SitePath, processedImage.Contents); err != nil {
+			return nil, buildSummary{}, fmt.Errorf("failed to write %s: %w", processedImage.SitePath, err)
+		}
+		outputSources[processedImage.SitePath] = processedImage.SourcePath
+	}
 
-		fd, err := os.Create(outputPath)
+	if err := writeAliases(site, templateData.Pages, outputSources, configYaml.AliasRedirects); err != nil {
+		return nil, buildSummary{}, err
+	}
+
+	summary := buildSummary{
+		Pages:  len(templateData.Pages),
+		Files:  len(assets),
+		Images: len(processedImages),
+	}
+	if fileMinifier != nil {
+		summary.OriginalSize = fileMinifier.originalSize
+		summary.MinifiedSize = fileMinifier.minifiedSize
+	}
+	return site, summary, nil
+}
+
+// ignoreMatcher returns the Matcher for the files that configYaml and the
+// .jennyignore file say to ignore.
+func ignoreMatcher(configYaml config.ConfigYaml) (*ignore.Matcher, error) {
+	root := filepath.Dir(configYaml.Path)
+	ignored, err := ignore.Read(root, configYaml.Ignore)
+	if err != nil {
+		return nil, &sourceFileError{
+			SourcePath: filepath.Join(root, ignore.FileName),
+			Err:        fmt.Errorf("failed to read ignore patterns: %w", err),
+		}
+	}
+	return ignored, nil
+}
+
+// parseTemplates parses the templates in templatesDir that are not
+// ignored, with funcs available to them.
+func parseTemplates(templatesDir string, ignored *ignore.Matcher, funcs template.FuncMap) (*template.Template, error) {
+	templatesGlob := filepath.Join(templatesDir, "*.gotmpl")
+	matches, err := filepath.Glob(templatesGlob)
+	if err != nil {
+		return nil, err
+	}
+	templatePaths := make([]string, 0, len(matches))
+	for _, match := range matches {
+		if !ignored.Ignored(match, false) {
+			templatePaths = append(templatePaths, match)
+		}
+	}
+	if len(templatePaths) == 0 {
+		return nil, fmt.Errorf("there are no templates matching %s", templatesGlob)
+	}
+	return template.New("").Funcs(funcs).ParseFiles(templatePaths...)
+}
+
+// assetFunc returns the asset template function, which returns the asset
+// at the given path relative to the input directory, so that templates
+// can refer to it by its URL even if it is fingerprinted.
+func assetFunc(assets []*asset.Asset) func(string) (*asset.Asset, error) {
+	assetsByName := make(map[string]*asset.Asset, len(assets))
+	for _, fileAsset := range assets {
+		assetsByName[fileAsset.Name] = fileAsset
+	}
+	return func(name string) (*asset.Asset, error) {
+		fileAsset, ok := assetsByName[strings.TrimPrefix(path.Clean("/"+name), "/")]
+		if !ok {
+			return nil, fmt.Errorf("there is no asset %s in %s", name, configYaml.Input)
+		}
+		return fileAsset, nil
+	}
+}
+
+// writeOutput replaces the contents of outputDir with the files in site.
+func writeOutput(site fs.FS, outputDir string) error {
+	// wipe output directory
+	if err := os.RemoveAll(outputDir); err != nil {
+		return fmt.Errorf("failed to wipe output dir: %w", err)
+	}
+	if err := os.MkdirAll(outputDir, 0o755); err != nil {
+		return fmt.Errorf("failed to ensure output dir exists: %w", err)
+	}
+
+	writeFileFunc := func(sitePath string, dirEntry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		outputPath := filepath.Join(outputDir, filepath.FromSlash(sitePath))
+		if dirEntry.IsDir() {
+			if err := os.MkdirAll(outputPath, 0o755); err != nil {
+				return fmt.Errorf("failed to create dir %s: %w", outputPath, err)
+			}
+			return nil
+		}
+		contents, err := fs.ReadFile(site, sitePath)
 		if err != nil {
-			return fmt.Errorf("failed to open %s: %w", outputPath, err)
+			return fmt.Errorf("failed to read %s from built site: %w", sitePath, err)
 		}
-		defer fd.Close()
-		if err := templates.ExecuteTemplate(fd, contentFile.Metadata.TemplateName, &templateData); err != nil {
-			return fmt.Errorf("failed to execute %s for %s: %w", contentFile.Metadata.TemplateName, outputPath, err)
+		if err := os.WriteFile(outputPath, contents, 0o644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", outputPath, err)
+		}
+		return nil
+	}
+
+	return fs.WalkDir(site, ".", writeFileFunc)
+}
+
+// renderPages converts the markdown content of each page to HTML, and
+// computes the values that are derived from it. Render hooks that ignored
+// ignores are not used. Images in markdown are made responsive with
+// resolveImage, if it is not nil.
+func renderPages(configYaml config.ConfigYaml, pages []*content.ContentFile, ignored *ignore.Matcher, resolveImage render.ImageResolver) error {
+	renderer, err := render.New(configYaml.Templates, ignored, resolveImage)
+	if err != nil {
+		return fmt.Errorf("failed to construct markdown renderer: %w", err)
+	}
+
+	for _, contentFile := range pages {
+		rawSummary, rawContent, hasDivider := content.SplitSummary(contentFile.RawContent)
+
+		builtContent, err := renderer.Convert(contentFile, rawContent)
+		if err != nil {
+			return &sourceFileError{
+				SourcePath: contentFile.SourcePath,
+				Err:        fmt.Errorf("failed to build %s: %w", contentFile.SourcePath, err),
+			}
+		}
+		contentFile.Content = builtContent
+		contentFile.WordCount = content.CountWords(builtContent)
+		contentFile.ReadingTime = content.ReadingTime(contentFile.WordCount)
+
+		if hasDivider {
+			builtSummary, err := renderer.Convert(contentFile, rawSummary)
+			if err != nil {
+				return &sourceFileError{
+					SourcePath: contentFile.SourcePath,
+					Err:        fmt.Errorf("failed to build summary of %s: %w", contentFile.SourcePath, err),
+				}
+			}
+			contentFile.Summary = builtSummary
+			contentFile.Truncated = true
+		} else {
+			contentFile.Summary, contentFile.Truncated = content.TruncateHTML(builtContent, configYaml.SummaryLength)
 		}
 	}
 
 	return nil
 }
 
-func gatherFileInfo(configYaml config.ConfigYaml) ([]string, TemplateData, error) {
+// gatherFileInfo reads the files in the input directory, other than those
+// that are ignored. Non-markdown files are minified with fileMinifier,
+// which may be nil, before they are fingerprinted.
+func gatherFileInfo(configYaml config.ConfigYaml, ignored *ignore.Matcher, fileMinifier *minifier) ([]*asset.Asset, TemplateData, error) {
 	nonMdFiles := make([]string, 0)
+	sourcePaths := map[string]string{}
 	templateData := TemplateData{
 		Pages: make([]*content.ContentFile, 0),
 		Computed: Computed{
@@ -121,6 +301,12 @@ func gatherFileInfo(configYaml config.ConfigYaml) ([]string, TemplateData, error
 		if err != nil {
 			return err
 		}
+		if inputPath != configYaml.Input && ignored.Ignored(inputPath, dirEntry.IsDir()) {
+			if dirEntry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
 		if dirEntry.IsDir() {
 			return nil
 		}
@@ -148,9 +334,20 @@ func gatherFileInfo(configYaml config.ConfigYaml) ([]string, TemplateData, error
 
 		contentFile, err := content.ReadFile(inputPath)
 		if err != nil {
-			return fmt.Errorf("failed to parse %s: %w", inputPath, err)
+			return &sourceFileError{
+				SourcePath: inputPath,
+				Err:        fmt.Errorf("failed to parse %s: %w", inputPath, err),
+			}
 		}
-		contentFile.Path = filepath.Join("/", relativeParentDir, parts[0]+".html")
+		outputName := parts[0] + ".html"
+		if fileName == content.SectionIndex {
+			outputName = "index.html"
+		}
+		contentFile.Path = filepath.Join("/", relativeParentDir, outputName)
+		if otherSourcePath, ok := sourcePaths[contentFile.Path]; ok {
+			return fmt.Errorf("%s and %s would both be built to %s", otherSourcePath, inputPath, contentFile.Path)
+		}
+		sourcePaths[contentFile.Path] = inputPath
 		templateData.Pages = append(templateData.Pages, contentFile)
 
 		return nil
@@ -160,25 +357,80 @@ func gatherFileInfo(configYaml config.ConfigYaml) ([]string, TemplateData, error
 		return nil, TemplateData{}, fmt.Errorf("failed to build: %w", err)
 	}
 
-	return nonMdFiles, templateData, nil
-}
+	assets := make([]*asset.Asset, 0, len(nonMdFiles))
+	for _, nonMdFile := range nonMdFiles {
+		name := filepath.ToSlash(nonMdFile)
+		sourcePath := filepath.Join(configYaml.Input, nonMdFile)
+		contents, err := os.ReadFile(sourcePath)
+		if err != nil {
+			return nil, TemplateData{}, fmt.Errorf("failed to read %s: %w", sourcePath, err)
+		}
+		contents, err = fileMinifier.minify(name, contents)
+		if err != nil {
+			return nil, TemplateData{}, &sourceFileError{SourcePath: sourcePath, Err: err}
+		}
+		assets = append(assets, asset.New(sourcePath, name, contents, asset.Match(configYaml.Fingerprint, name)))
+	}
 
-func copyFile(dst, src string) error {
-	srcFd, err := os.Open(src)
-	if err != nil {
-		return fmt.Errorf("failed to open source file: %w", err)
+	if err := attachResources(configYaml, templateData.Pages, assets); err != nil {
+		return nil, TemplateData{}, fmt.Errorf("failed to attach resources to page bundles: %w", err)
 	}
-	defer srcFd.Close()
-	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
-		return fmt.Errorf("failed to create destination parent dir: %w", err)
+
+	rootSection, err := content.BuildSections(configYaml.Input, templateData.Pages)
+	if err != nil {
+		return nil, TemplateData{}, fmt.Errorf("failed to build sections: %w", err)
 	}
-	dstFd, err := os.Create(dst)
+	templateData.Sections = rootSection.Sections
+
+	templateData.Data, err = data.Load(configYaml.Data, ignored)
 	if err != nil {
-		return fmt.Errorf("failed to open destination file: %w", err)
+		return nil, TemplateData{}, fmt.Errorf("failed to load data files: %w", err)
 	}
-	defer dstFd.Close()
-	if _, err := io.Copy(dstFd, srcFd); err != nil {
-		return fmt.Errorf("failed to copy contents of src to dst: %w", err)
+
+	return assets, templateData, nil
+}
+
+// attachResources makes each non-markdown file that is in a page bundle
+// a resource of that bundle's page. A page bundle is a directory other
+// than the input directory that contains an index.md, along with all of
+// its subdirectories.
+func attachResources(configYaml config.ConfigYaml, pages []*content.ContentFile, assets []*asset.Asset) error {
+	bundles := map[string]*content.ContentFile{}
+	for _, contentFile := range pages {
+		if filepath.Base(contentFile.SourcePath) != content.BundleIndex {
+			continue
+		}
+		bundleDir, err := filepath.Rel(configYaml.Input, filepath.Dir(contentFile.SourcePath))
+		if err != nil {
+			return fmt.Errorf("failed to get path of %s relative to %s: %w", contentFile.SourcePath, configYaml.Input, err)
+		}
+		// the home page is not a page bundle
+		if bundleDir == "." {
+			continue
+		}
+		bundles[bundleDir] = contentFile
+	}
+
+	for _, fileAsset := range assets {
+		nonMdFile := filepath.FromSlash(fileAsset.Name)
+		// find the closest enclosing bundle, if any
+		bundleDir := filepath.Dir(nonMdFile)
+		contentFile, ok := bundles[bundleDir]
+		for !ok && bundleDir != "." {
+			bundleDir = filepath.Dir(bundleDir)
+			contentFile, ok = bundles[bundleDir]
+		}
+		if !ok {
+			continue
+		}
+
+		name, err := filepath.Rel(bundleDir, nonMdFile)
+		if err != nil {
+			return fmt.Errorf("failed to get path of %s relative to %s: %w", nonMdFile, bundleDir, err)
+		}
+		resource := content.NewResource(filepath.ToSlash(name), fileAsset.SourcePath, fileAsset.URL, int64(len(fileAsset.Contents)))
+		contentFile.Resources = append(contentFile.Resources, resource)
 	}
+
 	return nil
 }
@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+
+	"github.com/adamkpickering/jenny/internal/notify"
+)
+
+// Matches the location that text/template includes in its errors, for
+// example "template: page.gotmpl:3:5:" or "template: page.gotmpl:3:".
+var templateLocationRegex = regexp.MustCompile(`template: ([^:\s]+):(\d+)(?::\d+)?:`)
+
+// sourceFileError associates an error with the input file that caused it.
+type sourceFileError struct {
+	SourcePath string
+	Err        error
+}
+
+func (err *sourceFileError) Error() string {
+	return err.Err.Error()
+}
+
+func (err *sourceFileError) Unwrap() error {
+	return err.Err
+}
+
+// newBuildError describes err in a form that can be shown to the user in
+// their browser.
+func newBuildError(err error) *notify.BuildError {
+	newError := &notify.BuildError{
+		Message: err.Error(),
+	}
+
+	var sourceErr *sourceFileError
+	if errors.As(err, &sourceErr) {
+		newError.SourcePath = sourceErr.SourcePath
+	}
+
+	// Use the innermost location, since that is where the error actually
+	// happened when one template is included by another.
+	matches := templateLocationRegex.FindAllStringSubmatch(newError.Message, -1)
+	if len(matches) > 0 {
+		match := matches[len(matches)-1]
+		newError.Template = match[1]
+		newError.Line, _ = strconv.Atoi(match[2])
+	}
+
+	return newError
+}
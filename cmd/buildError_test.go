@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestNewBuildError(t *testing.T) {
+	t.Run("should extract source file, template and line", func(t *testing.T) {
+		templateErr := errors.New(`template: header.gotmpl:12:5: executing "header.gotmpl" at <.Nope>: can't evaluate field Nope`)
+		err := fmt.Errorf("wrapped: %w", &sourceFileError{
+			SourcePath: "input/post1.md",
+			Err:        fmt.Errorf("failed to execute page.gotmpl for output/post1.html: %w", templateErr),
+		})
+		buildErr := newBuildError(err)
+		if buildErr.SourcePath != "input/post1.md" {
+			t.Errorf("got source path %q but expected %q", buildErr.SourcePath, "input/post1.md")
+		}
+		if buildErr.Template != "header.gotmpl" {
+			t.Errorf("got template %q but expected %q", buildErr.Template, "header.gotmpl")
+		}
+		if buildErr.Line != 12 {
+			t.Errorf("got line %d but expected %d", buildErr.Line, 12)
+		}
+		if buildErr.Message != err.Error() {
+			t.Errorf("got message %q but expected %q", buildErr.Message, err.Error())
+		}
+	})
+
+	t.Run("should handle template parse errors", func(t *testing.T) {
+		err := errors.New(`failed to parse templates: template: page.gotmpl:3: unexpected "}" in operand`)
+		buildErr := newBuildError(err)
+		if buildErr.SourcePath != "" {
+			t.Errorf("got source path %q but expected none", buildErr.SourcePath)
+		}
+		if buildErr.Template != "page.gotmpl" || buildErr.Line != 3 {
+			t.Errorf("got template %q line %d but expected page.gotmpl line 3", buildErr.Template, buildErr.Line)
+		}
+	})
+
+	t.Run("should handle errors without a location", func(t *testing.T) {
+		buildErr := newBuildError(errors.New("failed to wipe output dir"))
+		if buildErr.Template != "" || buildErr.Line != 0 || buildErr.SourcePath != "" {
+			t.Errorf("got unexpected location in %+v", buildErr)
+		}
+	})
+}
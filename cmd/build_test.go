@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/adamkpickering/jenny/internal/asset"
+	"github.com/adamkpickering/jenny/internal/config"
+	"github.com/adamkpickering/jenny/internal/content"
+)
+
+func TestAssetFunc(t *testing.T) {
+	assets := []*asset.Asset{
+		{Name: "static/style.css", SitePath: "static/style.0123456789abcdef.css", URL: "/static/style.0123456789abcdef.css"},
+	}
+	assetByName := assetFunc(assets)
+
+	for _, name := range []string{"static/style.css", "/static/style.css", "./static/style.css"} {
+		t.Run("should find asset by "+name, func(t *testing.T) {
+			fileAsset, err := assetByName(name)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if fileAsset != assets[0] {
+				t.Errorf("got %v but expected %v", fileAsset, assets[0])
+			}
+		})
+	}
+
+	t.Run("should return error for missing asset", func(t *testing.T) {
+		if _, err := assetByName("static/missing.css"); err == nil {
+			t.Errorf("did not get error when we should have")
+		}
+	})
+}
+
+func TestAttachResources(t *testing.T) {
+	t.Run("should use the size of the file in the built site", func(t *testing.T) {
+		configYaml := config.ConfigYaml{Input: "input"}
+		page := &content.ContentFile{SourcePath: "input/post/index.md"}
+		// the source file is larger, but was minified
+		fileAsset := asset.New("input/post/style.css", "post/style.css", []byte("a{color:red}"), false)
+		if err := attachResources(configYaml, []*content.ContentFile{page}, []*asset.Asset{fileAsset}); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if len(page.Resources) != 1 {
+			t.Fatalf("got %d resources but expected 1", len(page.Resources))
+		}
+		if size := page.Resources[0].Size; size != 12 {
+			t.Errorf("got size %d but expected 12", size)
+		}
+	})
+}
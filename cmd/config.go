@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"slices"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+func init() {
+	rootCmd.AddCommand(configCmd)
+}
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Print the configuration that is used, with defaults and environment overrides applied",
+	Args:  cobra.NoArgs,
+	RunE:  runConfig,
+}
+
+func runConfig(cmd *cobra.Command, args []string) error {
+	for _, configFile := range configFiles(configYaml) {
+		if _, err := os.Stat(configFile); errors.Is(err, fs.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return fmt.Errorf("failed to stat %s: %w", configFile, err)
+		}
+		fmt.Printf("# read from %s\n", configFile)
+	}
+
+	node := &yaml.Node{}
+	if err := node.Encode(configYaml); err != nil {
+		return fmt.Errorf("failed to encode config to yaml: %w", err)
+	}
+	// Environment cannot be set in configuration files, so it is shown as
+	// a comment, so that the output can be used as a configuration file.
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == "Environment" {
+			node.Content = slices.Delete(node.Content, i, i+2)
+			break
+		}
+	}
+	fmt.Printf("# environment: %s\n", configYaml.Environment)
+	encoder := yaml.NewEncoder(os.Stdout)
+	if err := encoder.Encode(node); err != nil {
+		return fmt.Errorf("failed to encode config to yaml: %w", err)
+	}
+
+	if err := configYaml.Validate(); err != nil {
+		return fmt.Errorf("invalid config: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"path/filepath"
+	"text/template"
+
+	"github.com/adamkpickering/jenny/internal/asset"
+	"github.com/adamkpickering/jenny/internal/config"
+	"github.com/adamkpickering/jenny/internal/content"
+	"github.com/adamkpickering/jenny/internal/images"
+	"github.com/adamkpickering/jenny/internal/render"
+)
+
+// imageFuncs returns the image template functions, which process the
+// images in assets with processor. Each of them takes the image to
+// process as either its path relative to the input directory, as for the
+// asset function, or a resource of a page bundle.
+func imageFuncs(processor *images.Processor, assets []*asset.Asset, options config.ImageOptions) template.FuncMap {
+	findImage := sourceImageFunc(assets)
+	return template.FuncMap{
+		"image": func(source any) (*images.Image, error) {
+			fileAsset, err := findImage(source)
+			if err != nil {
+				return nil, err
+			}
+			return processor.Original(fileAsset)
+		},
+		"imageFill": func(source any, spec string) (*images.Image, error) {
+			fileAsset, err := findImage(source)
+			if err != nil {
+				return nil, err
+			}
+			return processor.Fill(fileAsset, spec)
+		},
+		"imageResize": func(source any, spec string) (*images.Image, error) {
+			fileAsset, err := findImage(source)
+			if err != nil {
+				return nil, err
+			}
+			return processor.Resize(fileAsset, spec)
+		},
+		"imageSrcset": func(source any) (*images.Responsive, error) {
+			if len(options.Widths) == 0 {
+				return nil, fmt.Errorf("there are no widths to resize images to in Images.Widths")
+			}
+			fileAsset, err := findImage(source)
+			if err != nil {
+				return nil, err
+			}
+			return processor.Srcset(fileAsset, options.Widths, options.Sizes)
+		},
+	}
+}
+
+// sourceImageFunc returns a function that returns the asset of the image
+// that source refers to, which is either the path of a file relative to
+// the input directory or a resource of a page bundle.
+func sourceImageFunc(assets []*asset.Asset) func(source any) (*asset.Asset, error) {
+	findAsset := assetFunc(assets)
+	assetsBySourcePath := make(map[string]*asset.Asset, len(assets))
+	for _, fileAsset := range assets {
+		assetsBySourcePath[fileAsset.SourcePath] = fileAsset
+	}
+	return func(source any) (*asset.Asset, error) {
+		switch source := source.(type) {
+		case string:
+			return findAsset(source)
+		case *content.Resource:
+			fileAsset, ok := assetsBySourcePath[source.SourcePath]
+			if !ok {
+				return nil, fmt.Errorf("there is no asset for resource %s", source.Name)
+			}
+			return fileAsset, nil
+		case *asset.Asset:
+			return source, nil
+		}
+		return nil, fmt.Errorf("cannot process %v as an image: must be a path or a resource", source)
+	}
+}
+
+// imageResolver returns the function that finds the responsive versions
+// of the images in markdown, or nil if there are no widths to resize them
+// to. Images are referred to by their URL in the built site, by their path
+// relative to the input directory, or by their path relative to the page.
+func imageResolver(processor *images.Processor, assets []*asset.Asset, options config.ImageOptions) render.ImageResolver {
+	if len(options.Widths) == 0 {
+		return nil
+	}
+	assetsByURL := make(map[string]*asset.Asset, 2*len(assets))
+	for _, fileAsset := range assets {
+		assetsByURL["/"+fileAsset.Name] = fileAsset
+		assetsByURL[fileAsset.URL] = fileAsset
+	}
+	return func(page *content.ContentFile, destination string) (*images.Responsive, error) {
+		destinationURL, err := url.Parse(destination)
+		if err != nil || destinationURL.IsAbs() || destinationURL.Host != "" || destinationURL.Path == "" {
+			return nil, nil
+		}
+		urlPath := destinationURL.Path
+		if !path.IsAbs(urlPath) {
+			pageDir := "/"
+			if page != nil {
+				pageDir = path.Dir(filepath.ToSlash(page.Path))
+			}
+			urlPath = path.Join(pageDir, urlPath)
+		}
+		fileAsset, ok := assetsByURL[path.Clean(urlPath)]
+		if !ok || !images.Supported(fileAsset.Name) {
+			return nil, nil
+		}
+		return processor.Srcset(fileAsset, options.Widths, options.Sizes)
+	}
+}
@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"testing"
+
+	"github.com/adamkpickering/jenny/internal/asset"
+	"github.com/adamkpickering/jenny/internal/config"
+	"github.com/adamkpickering/jenny/internal/content"
+	"github.com/adamkpickering/jenny/internal/images"
+)
+
+func TestImageResolver(t *testing.T) {
+	encoded := &bytes.Buffer{}
+	if err := png.Encode(encoded, image.NewRGBA(image.Rect(0, 0, 40, 20))); err != nil {
+		t.Fatalf("failed to encode test image: %s", err)
+	}
+	assets := []*asset.Asset{
+		asset.New("input/posts/trip/photo.png", "posts/trip/photo.png", encoded.Bytes(), true),
+		asset.New("input/static/style.css", "static/style.css", []byte("a{}"), false),
+	}
+	options := config.ImageOptions{Quality: 75, Sizes: "100vw", Widths: []int{20, 80}}
+	page := &content.ContentFile{Path: "/posts/trip/index.html"}
+
+	t.Run("should be nil when there are no widths", func(t *testing.T) {
+		if imageResolver(images.New(t.TempDir(), 75, false), assets, config.ImageOptions{}) != nil {
+			t.Errorf("got resolver but expected nil")
+		}
+	})
+
+	for _, destination := range []string{"photo.png", "/posts/trip/photo.png", assets[0].URL} {
+		t.Run("should resolve "+destination, func(t *testing.T) {
+			resolveImage := imageResolver(images.New(t.TempDir(), 75, false), assets, options)
+			responsive, err := resolveImage(page, destination)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if responsive == nil {
+				t.Fatalf("got nil but expected responsive image")
+			}
+			if len(responsive.Images) != 2 || responsive.Width != 40 || responsive.Height != 20 {
+				t.Errorf("got %d images with largest %dx%d but expected 2 with largest 40x20", len(responsive.Images), responsive.Width, responsive.Height)
+			}
+		})
+	}
+
+	for _, destination := range []string{"/static/style.css", "https://example.com/photo.png", "missing.png"} {
+		t.Run("should not resolve "+destination, func(t *testing.T) {
+			resolveImage := imageResolver(images.New(t.TempDir(), 75, false), assets, options)
+			responsive, err := resolveImage(page, destination)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if responsive != nil {
+				t.Errorf("got %v but expected nil", responsive)
+			}
+		})
+	}
+}
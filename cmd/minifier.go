@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/adamkpickering/jenny/internal/minify"
+)
+
+// minifier minifies the files whose media types are configured to be
+// minified, and keeps track of how much smaller it makes them.
+type minifier struct {
+	mediaTypes   []string
+	originalSize int
+	minifiedSize int
+}
+
+// minify returns the minified contents of the file with the given name, or
+// contents as they are if files of its media type are not minified. A nil
+// minifier minifies nothing.
+func (m *minifier) minify(name string, contents []byte) ([]byte, error) {
+	if m == nil {
+		return contents, nil
+	}
+	mediaType := minify.MediaType(name)
+	if mediaType == "" || !slices.Contains(m.mediaTypes, mediaType) {
+		return contents, nil
+	}
+	minified, err := minify.Minify(mediaType, contents)
+	if err != nil {
+		return nil, fmt.Errorf("failed to minify %s: %w", name, err)
+	}
+	m.originalSize += len(contents)
+	m.minifiedSize += len(minified)
+	return minified, nil
+}
+
+// buildSummary describes what a build produced.
+type buildSummary struct {
+	Pages int
+	Files int
+	// The number of images that were processed from other files.
+	Images int
+	// The total size of the minified files before and after minification.
+	OriginalSize int
+	MinifiedSize int
+}
+
+func (summary buildSummary) String() string {
+	description := fmt.Sprintf("built %s and %s", plural(summary.Pages, "page"), plural(summary.Files, "other file"))
+	if summary.Images > 0 {
+		description += "; processed " + plural(summary.Images, "image")
+	}
+	if summary.OriginalSize == 0 {
+		return description
+	}
+	saved := summary.OriginalSize - summary.MinifiedSize
+	percentage := float64(saved) / float64(summary.OriginalSize) * 100
+	return fmt.Sprintf("%s; minification saved %s of %s (%.1f%%)", description, formatSize(saved), formatSize(summary.OriginalSize), percentage)
+}
+
+// plural returns count followed by noun, in the plural if count is not 1.
+func plural(count int, noun string) string {
+	if count == 1 {
+		return fmt.Sprintf("%d %s", count, noun)
+	}
+	return fmt.Sprintf("%d %ss", count, noun)
+}
+
+// formatSize formats a number of bytes in the largest unit that keeps it
+// at least 1.
+func formatSize(size int) string {
+	switch {
+	case size >= 1000*1000:
+		return fmt.Sprintf("%.1f MB", float64(size)/(1000*1000))
+	case size >= 1000:
+		return fmt.Sprintf("%.1f kB", float64(size)/1000)
+	}
+	return fmt.Sprintf("%d B", size)
+}
@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/adamkpickering/jenny/internal/minify"
+)
+
+func TestMinifier(t *testing.T) {
+	t.Run("should minify configured media types and count savings", func(t *testing.T) {
+		fileMinifier := &minifier{mediaTypes: []string{minify.CSS}}
+		minified, err := fileMinifier.minify("static/style.css", []byte("a { color: red; }\n"))
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if string(minified) != "a{color:red}" {
+			t.Errorf("got %q but expected %q", minified, "a{color:red}")
+		}
+		if fileMinifier.originalSize != 18 || fileMinifier.minifiedSize != 12 {
+			t.Errorf("got sizes %d and %d but expected 18 and 12", fileMinifier.originalSize, fileMinifier.minifiedSize)
+		}
+	})
+
+	t.Run("should leave other media types alone", func(t *testing.T) {
+		fileMinifier := &minifier{mediaTypes: []string{minify.CSS}}
+		contents := []byte("let a = 1;\n")
+		minified, err := fileMinifier.minify("static/app.js", contents)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if string(minified) != string(contents) {
+			t.Errorf("got %q but expected %q", minified, contents)
+		}
+		if fileMinifier.originalSize != 0 {
+			t.Errorf("got original size %d but expected 0", fileMinifier.originalSize)
+		}
+	})
+
+	t.Run("should minify nothing when nil", func(t *testing.T) {
+		var fileMinifier *minifier
+		contents := []byte("a { color: red; }\n")
+		minified, err := fileMinifier.minify("static/style.css", contents)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if string(minified) != string(contents) {
+			t.Errorf("got %q but expected %q", minified, contents)
+		}
+	})
+}
+
+func TestBuildSummary(t *testing.T) {
+	cases := []struct {
+		description string
+		summary     buildSummary
+		expected    string
+	}{
+		{"should omit savings when nothing was minified", buildSummary{Pages: 3, Files: 2}, "built 3 pages and 2 other files"},
+		{"should use singular nouns for one of something", buildSummary{Pages: 1, Files: 1}, "built 1 page and 1 other file"},
+		{"should report processed images", buildSummary{Pages: 3, Files: 2, Images: 4}, "built 3 pages and 2 other files; processed 4 images"},
+		{"should report savings", buildSummary{Pages: 3, Files: 2, OriginalSize: 4000, MinifiedSize: 3000}, "built 3 pages and 2 other files; minification saved 1.0 kB of 4.0 kB (25.0%)"},
+	}
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			if got := c.summary.String(); got != c.expected {
+				t.Errorf("got %q but expected %q", got, c.expected)
+			}
+		})
+	}
+}
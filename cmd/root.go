@@ -1,14 +1,62 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
 
 	"github.com/adamkpickering/jenny/internal/config"
 	"github.com/spf13/cobra"
 )
 
-var configYaml config.ConfigYaml
+var (
+	configOverrides map[string]string
+	configPath      string
+	configYaml      config.ConfigYaml
+	environment     string
+	sourceDir       string
+)
+
+// The environment variable that sets the environment if --environment is
+// not given.
+const environmentVariable = "JENNY_ENVIRONMENT"
+
+func init() {
+	rootCmd.PersistentFlags().StringVar(&configPath, "config", "", "path to the configuration file (default: "+config.FileName+" in the current directory or the closest parent directory that has one)")
+	rootCmd.PersistentFlags().StringVar(&environment, "environment", "", `environment to use the configuration of (overrides `+environmentVariable+`; default "development" for serve and "production" otherwise)`)
+	rootCmd.PersistentFlags().StringVar(&sourceDir, "source", "", "path to the directory of the site, which contains "+config.FileName)
+	for _, override := range config.Overrides {
+		value := &overrideValue{typeName: override.Type()}
+		usage := fmt.Sprintf("%s (overrides %s and %s)", override.Usage, override.Field, override.EnvironmentVariable())
+		flag := rootCmd.PersistentFlags().VarPF(value, override.Flag, "", usage)
+		if value.typeName == "bool" {
+			// so that --images-keep-exif works without a value
+			flag.NoOptDefVal = "true"
+		}
+	}
+}
+
+// overrideValue is the value of a flag that overrides a field of the
+// configuration. The value is parsed by config.Get, like values from the
+// configuration file are.
+type overrideValue struct {
+	typeName string
+	value    string
+}
+
+func (value *overrideValue) String() string {
+	return value.value
+}
+
+func (value *overrideValue) Set(s string) error {
+	value.value = s
+	return nil
+}
+
+func (value *overrideValue) Type() string {
+	return value.typeName
+}
 
 var rootCmd = &cobra.Command{
 	Use: "jenny",
@@ -19,14 +67,80 @@ var rootCmd = &cobra.Command{
 }
 
 func populateConfigYaml(cmd *cobra.Command, args []string) error {
-	var err error
-	configYaml, err = config.Get()
+	if environment == "" {
+		environment = os.Getenv(environmentVariable)
+	}
+	if environment == "" {
+		environment = config.Production
+		if cmd == serveCmd {
+			environment = config.Development
+		}
+	}
+	path, err := findConfig()
+	if err != nil {
+		return err
+	}
+	configOverrides = getConfigOverrides(cmd)
+	configYaml, err = config.Get(path, environment, configOverrides)
 	if err != nil {
 		return fmt.Errorf("failed to get config: %w", err)
 	}
+	// config prints the config even if it is invalid
+	if cmd == configCmd {
+		return nil
+	}
+	if err := configYaml.Validate(); err != nil {
+		return fmt.Errorf("invalid config: %w", err)
+	}
 	return nil
 }
 
+// getConfigOverrides returns the values of the fields of the configuration
+// that are set by environment variables or by the flags of cmd. Flags take
+// precedence over environment variables.
+func getConfigOverrides(cmd *cobra.Command) map[string]string {
+	overrides := map[string]string{}
+	for _, override := range config.Overrides {
+		if value := os.Getenv(override.EnvironmentVariable()); value != "" {
+			overrides[override.Field] = value
+		}
+		if flag := cmd.Flags().Lookup(override.Flag); flag != nil && flag.Changed {
+			overrides[override.Field] = flag.Value.String()
+		}
+	}
+	return overrides
+}
+
+// findConfig returns the path to the configuration file, which is given
+// by --config or --source or else found by searching upwards from the
+// current directory.
+func findConfig() (string, error) {
+	switch {
+	case configPath != "" && sourceDir != "":
+		return "", errors.New("--config and --source cannot be used together")
+	case configPath != "":
+		if _, err := os.Stat(configPath); err != nil {
+			return "", fmt.Errorf("failed to find config file: %w", err)
+		}
+		return filepath.Clean(configPath), nil
+	case sourceDir != "":
+		info, err := os.Stat(sourceDir)
+		if err != nil {
+			return "", fmt.Errorf("failed to find source directory: %w", err)
+		}
+		if !info.IsDir() {
+			return "", fmt.Errorf("source %s is not a directory", sourceDir)
+		}
+		return filepath.Join(sourceDir, config.FileName), nil
+	default:
+		path, err := config.Find(".")
+		if err != nil {
+			return "", fmt.Errorf("failed to find config file: %w", err)
+		}
+		return path, nil
+	}
+}
+
 func Execute() {
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Printf("error: %s\n", err)
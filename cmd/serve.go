@@ -1,39 +1,124 @@
 package cmd
 
 import (
+	"bytes"
 	"context"
+	"crypto/tls"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io/fs"
 	"log"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
 	"path/filepath"
 	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/adamkpickering/jenny/internal/asset"
+	"github.com/adamkpickering/jenny/internal/config"
+	"github.com/adamkpickering/jenny/internal/ignore"
 	"github.com/adamkpickering/jenny/internal/notify"
+	"github.com/adamkpickering/jenny/internal/watch"
 	"github.com/coder/websocket"
-	"github.com/fsnotify/fsnotify"
 	"github.com/spf13/cobra"
 )
 
 const reloadScript = `<script>
   let wsUrl = new URL(window.location.href);
+  wsUrl.protocol = wsUrl.protocol === "https:" ? "wss:" : "ws:";
   wsUrl.pathname = "/websocket";
+  wsUrl.search = "?" + new URLSearchParams({page: window.location.pathname});
+  wsUrl.hash = "";
   let ws = new WebSocket(wsUrl);
   ws.onmessage = (event) => {
-    if (event.data === "reload") {
+    let message = JSON.parse(event.data);
+    if (message.type === "reload") {
       window.location.reload();
+    } else if (message.type === "css") {
+      reloadStylesheets(message.paths);
+    } else if (message.type === "error") {
+      showBuildError(message.error);
     }
   }
+  function reloadStylesheets(paths) {
+    let links = Array.from(document.querySelectorAll('link[rel="stylesheet"]'));
+    let changedLinks = links.filter((link) => paths.includes(new URL(link.href).pathname));
+    // The changed stylesheets may be imported by other stylesheets, so
+    // if none of them are linked directly, re-fetch all of them.
+    if (changedLinks.length === 0) {
+      changedLinks = links;
+    }
+    for (let link of changedLinks) {
+      let url = new URL(link.href);
+      url.searchParams.set("jenny-reload", Date.now());
+      // The new contents would not match the old integrity hash.
+      link.removeAttribute("integrity");
+      link.href = url;
+    }
+  }
+  function showBuildError(buildError) {
+    if (document.body === null) {
+      document.addEventListener("DOMContentLoaded", () => showBuildError(buildError));
+      return;
+    }
+    let overlay = document.getElementById("jenny-build-error");
+    if (overlay === null) {
+      overlay = document.createElement("div");
+      overlay.id = "jenny-build-error";
+      overlay.style = "position: fixed; inset: 0; z-index: 2147483647; overflow: auto; padding: 2em;" +
+        "background: rgba(0, 0, 0, 0.85); color: #fff; font: 14px/1.5 monospace;";
+      document.body.appendChild(overlay);
+    }
+    let heading = document.createElement("h2");
+    heading.textContent = "jenny: build failed";
+    heading.style = "color: #ff6b6b; font: inherit; font-size: 1.5em;";
+    let details = document.createElement("p");
+    let location = [];
+    if (buildError.sourcePath) {
+      location.push("source file " + buildError.sourcePath);
+    }
+    if (buildError.template) {
+      location.push("template " + buildError.template + (buildError.line ? " line " + buildError.line : ""));
+    }
+    details.textContent = location.join(", ");
+    let message = document.createElement("pre");
+    message.textContent = buildError.message;
+    message.style = "white-space: pre-wrap;";
+    overlay.replaceChildren(heading, details, message);
+  }
 </script>`
 
-var host string
+var (
+	allowedOrigins []string
+	bind           string
+	debounce       time.Duration
+	minifyServed   bool
+	publicURL      string
+	tlsCertPath    string
+	tlsEnabled     bool
+	tlsKeyPath     string
+)
 
 func init() {
-	serveCmd.PersistentFlags().StringVar(&host, "host", "localhost:9023", "host and port to listen on in host:port format")
+	serveCmd.PersistentFlags().StringSliceVar(&allowedOrigins, "allowed-origin", nil, "host (or host pattern) of other origins whose pages may connect for reloading; may be repeated")
+	serveCmd.PersistentFlags().StringVar(&bind, "bind", "localhost:9023", "address to listen on in host:port format; use a host of 0.0.0.0 or leave it empty to listen on all interfaces")
+	serveCmd.PersistentFlags().DurationVar(&debounce, "debounce", 100*time.Millisecond, "how long to wait after a change for more changes before rebuilding")
+	serveCmd.PersistentFlags().StringVar(&bind, "host", "localhost:9023", "host and port to listen on in host:port format")
+	cobra.CheckErr(serveCmd.PersistentFlags().MarkDeprecated("host", "use --bind instead"))
+	serveCmd.PersistentFlags().BoolVar(&minifyServed, "minify", false, "minify the files of the media types in the Minify config field, as jenny build does")
+	serveCmd.PersistentFlags().StringVar(&publicURL, "public-url", "", "URL at which the site is reached through a proxy or tunnel, if any")
+	serveCmd.PersistentFlags().BoolVar(&tlsEnabled, "tls", false, "serve over HTTPS with a certificate signed by a local CA that is created on first use")
+	serveCmd.PersistentFlags().StringVar(&tlsCertPath, "tls-cert", "", "path to a PEM certificate to serve over HTTPS with instead of the generated one; implies --tls")
+	serveCmd.PersistentFlags().StringVar(&tlsKeyPath, "tls-key", "", "path to the PEM private key of the certificate given with --tls-cert")
 	rootCmd.AddCommand(serveCmd)
 }
 
@@ -45,32 +130,82 @@ var serveCmd = &cobra.Command{
 }
 
 func runServe(cmd *cobra.Command, args []string) error {
-	// We do not want to modify the files in the actual output
-	// directory as part of this command.
-	tempOutputDir, err := os.MkdirTemp("", "jenny-serve-output-*")
+	if publicURL != "" {
+		parsedURL, err := url.Parse(publicURL)
+		if err != nil || (parsedURL.Scheme != "http" && parsedURL.Scheme != "https") || parsedURL.Host == "" {
+			return fmt.Errorf("public URL %q is not an absolute http or https URL", publicURL)
+		}
+	}
+	patterns, err := originPatterns(publicURL, allowedOrigins)
+	if err != nil {
+		return err
+	}
+	if (tlsCertPath == "") != (tlsKeyPath == "") {
+		return errors.New("--tls-cert and --tls-key must be given together")
+	}
+	useTLS := tlsEnabled || tlsCertPath != ""
+	scheme := "http"
+	if useTLS {
+		scheme = "https"
+	}
+
+	listener, err := net.Listen("tcp", bind)
+	if err != nil {
+		return fmt.Errorf("failed to listen: %w", err)
+	}
+	interfaceAddrs, err := net.InterfaceAddrs()
+	if err != nil {
+		log.Printf("failed to list network addresses: %s", err)
+	}
+	// Use the host as given, so that for example "localhost" is not
+	// replaced by 127.0.0.1, but the port that was actually chosen.
+	bindHost, _, _ := net.SplitHostPort(bind)
+	_, port, _ := net.SplitHostPort(listener.Addr().String())
+	urls, err := siteURLs(scheme, net.JoinHostPort(bindHost, port), interfaceAddrs)
 	if err != nil {
-		return fmt.Errorf("failed to get temporary directory: %w", err)
+		listener.Close()
+		return err
+	}
+	if useTLS {
+		cert, err := loadCertificate(urls)
+		if err != nil {
+			listener.Close()
+			return err
+		}
+		// Websockets need HTTP/1.1, so HTTP/2 is not offered.
+		listener = tls.NewListener(listener, &tls.Config{
+			Certificates: []tls.Certificate{cert},
+			NextProtos:   []string{"http/1.1"},
+		})
+	}
+	if publicURL != "" {
+		urls = append([]string{publicURL}, urls...)
 	}
-	configYaml.Output = tempOutputDir
-	log.Printf("using output directory %s", configYaml.Output)
 
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
 
 	notifier := notify.New()
 	defer notifier.CloseAll()
 
-	go watchAndBuild(ctx, stop, notifier)
+	site := &liveSite{}
+
+	go watchAndBuild(ctx, stop, notifier, site)
 
 	mux := http.NewServeMux()
-	mux.HandleFunc("/", addLogging(http.FileServerFS(os.DirFS(configYaml.Output))))
-	mux.HandleFunc("/websocket", handleWebsocket(notifier))
+	mux.HandleFunc("/", addLogging(site))
+	mux.HandleFunc("/websocket", handleWebsocket(notifier, site, patterns))
+	mux.HandleFunc("/_jenny/clients", handleClients(notifier))
 	server := http.Server{
-		Addr:    host,
 		Handler: mux,
 	}
 	go func() {
-		log.Printf("listening on http://%s", host)
-		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Printf("listening on %s; site available at:", listener.Addr())
+		// Print the URLs on their own, so that they are easy to copy or
+		// to turn into QR codes for opening the site on a phone.
+		for _, siteURL := range urls {
+			fmt.Println(siteURL)
+		}
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
 			log.Printf("http server error: %s", err)
 			stop()
 		}
@@ -92,13 +227,76 @@ func addLogging(handler http.Handler) http.HandlerFunc {
 	}
 }
 
-func handleWebsocket(notifier *notify.Notifier) func(rw http.ResponseWriter, req *http.Request) {
+// liveSite serves the most recent successful build, and keeps track of
+// whether the most recent build failed.
+type liveSite struct {
+	lock       sync.RWMutex
+	handler    http.Handler
+	buildError *notify.BuildError
+}
+
+func (site *liveSite) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	site.lock.RLock()
+	handler := site.handler
+	site.lock.RUnlock()
+
+	// If there has not been a successful build yet, serve a page that
+	// only contains the reload script, so that the user sees the error
+	// and gets the site once it builds.
+	if handler == nil {
+		rw.Header().Set("Content-Type", "text/html; charset=utf-8")
+		rw.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprintf(rw, "<!DOCTYPE html><html><head>%s</head><body></body></html>", reloadScript)
+		return
+	}
+	handler.ServeHTTP(rw, req)
+}
+
+// update makes the site serve builtSite.
+func (site *liveSite) update(builtSite fs.FS) {
+	handler, err := newStaticSite(builtSite, configYaml.NotFoundPage)
+	if err != nil {
+		log.Println(err)
+	}
+
+	site.lock.Lock()
+	defer site.lock.Unlock()
+	site.handler = injectReloadScriptMiddleware(handler)
+	site.buildError = nil
+}
+
+// fail records that the most recent build failed. The last successful
+// build continues to be served.
+func (site *liveSite) fail(err *notify.BuildError) {
+	site.lock.Lock()
+	defer site.lock.Unlock()
+	site.buildError = err
+}
+
+// message returns the message that should be sent to the reload script
+// to reflect the result of the most recent build.
+func (site *liveSite) message() notify.Message {
+	site.lock.RLock()
+	defer site.lock.RUnlock()
+	if site.buildError != nil {
+		return notify.Message{Type: notify.Error, Error: site.buildError}
+	}
+	return notify.Message{Type: notify.Reload}
+}
+
+// handleWebsocket sends messages from notifier over a websocket. Pages from
+// the host of the request, or from an origin that matches one of
+// originPatterns, may connect.
+func handleWebsocket(notifier *notify.Notifier, site *liveSite, originPatterns []string) func(rw http.ResponseWriter, req *http.Request) {
 	return func(rw http.ResponseWriter, req *http.Request) {
-		notifier.Register(req.RemoteAddr)
-		defer notifier.Close(req.RemoteAddr)
+		subscription := notifier.Subscribe(notify.SubscriberInfo{
+			Page:       req.URL.Query().Get("page"),
+			RemoteAddr: req.RemoteAddr,
+		})
+		defer subscription.Unsubscribe()
 
 		opts := &websocket.AcceptOptions{
-			InsecureSkipVerify: true,
+			OriginPatterns: originPatterns,
 		}
 		conn, err := websocket.Accept(rw, req, opts)
 		if err != nil {
@@ -108,6 +306,15 @@ func handleWebsocket(notifier *notify.Notifier) func(rw http.ResponseWriter, req
 		defer conn.CloseNow()
 		readCtx := conn.CloseRead(context.Background())
 
+		// A page that is loaded while the build is broken should show the
+		// error straight away.
+		if message := site.message(); message.Type == notify.Error {
+			if err := writeMessage(conn, message); err != nil {
+				log.Printf("failed to write: %s", err)
+				return
+			}
+		}
+
 		for {
 			select {
 			case <-readCtx.Done():
@@ -115,8 +322,14 @@ func handleWebsocket(notifier *notify.Notifier) func(rw http.ResponseWriter, req
 					log.Printf("failed to close: %s", err)
 				}
 				return
-			case <-notifier.Get(req.RemoteAddr):
-				if err := conn.Write(context.Background(), websocket.MessageText, []byte("reload")); err != nil {
+			case message, ok := <-subscription.Messages:
+				if !ok {
+					if err := conn.Close(websocket.StatusGoingAway, ""); err != nil {
+						log.Printf("failed to close: %s", err)
+					}
+					return
+				}
+				if err := writeMessage(conn, message); err != nil {
 					log.Printf("failed to write: %s", err)
 					return
 				}
@@ -125,143 +338,363 @@ func handleWebsocket(notifier *notify.Notifier) func(rw http.ResponseWriter, req
 	}
 }
 
-func watchAndBuild(ctx context.Context, stop func(), notifier *notify.Notifier) {
-	var watcher *fsnotify.Watcher
-	var err error
-	filePath := ""
+// handleClients lists the clients that are connected to /websocket, and
+// the page that each is viewing, as JSON. It is meant for debugging.
+func handleClients(notifier *notify.Notifier) http.HandlerFunc {
+	return func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("Content-Type", "application/json")
+		encoder := json.NewEncoder(rw)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(notifier.Subscribers()); err != nil {
+			log.Printf("failed to write client list: %s", err)
+		}
+	}
+}
 
-	// initial build
-	if err := build(); err != nil {
-		log.Printf("failed to build: %s", err)
+func writeMessage(conn *websocket.Conn, message notify.Message) error {
+	encodedMessage, err := json.Marshal(message)
+	if err != nil {
+		return fmt.Errorf("failed to encode message: %w", err)
+	}
+	return conn.Write(context.Background(), websocket.MessageText, encodedMessage)
+}
+
+func watchAndBuild(ctx context.Context, stop func(), notifier *notify.Notifier, site *liveSite) {
+	defer stop()
+
+	watcher, err := watch.New(debounce)
+	if err != nil {
+		log.Println(err)
 		return
 	}
-	if err := modifyHtmlFiles(); err != nil {
-		log.Printf("failed to modify HTML files: %s", err)
+	defer watcher.Close()
+	updateWatchIgnored()
+	watcher.SetIgnored(func(filePath string, isDir bool) bool {
+		ignored := watchIgnored.Load()
+		return ignored != nil && ignored.Ignored(filePath, isDir)
+	})
+	if err := updateWatches(watcher, config.ConfigYaml{}, configYaml); err != nil {
+		log.Println(err)
 		return
 	}
+	watcher.Start()
 
-forloop:
-	for {
-		// construct new directory watcher
-		if watcher != nil {
-			watcher.Close()
-		}
-		watcher, err = fsnotify.NewWatcher()
-		if err != nil {
-			log.Printf("failed to construct watcher: %s", err)
-			break forloop
-		}
-		if err := watcher.Add(configYaml.Templates); err != nil {
-			log.Printf("failed to watch %s: %s", configYaml.Templates, err)
-			break forloop
-		}
-		err = filepath.WalkDir(configYaml.Input, func(walkPath string, dirEntry fs.DirEntry, err error) error {
-			if err != nil {
-				return err
-			}
-			if !dirEntry.IsDir() {
-				return nil
-			}
-			if err := watcher.Add(walkPath); err != nil {
-				return fmt.Errorf("failed to watch %s: %s", walkPath, err)
-			}
-			return nil
-		})
-		if err != nil {
-			log.Println(err)
-			break forloop
-		}
+	// initial build
+	rebuild(site)
 
+	var configErr error
+	for {
 		// wait for something to happen
+		var changedPaths []string
 		select {
-		case _, ok := <-ctx.Done():
-			if !ok {
-				break forloop
-			}
-		case event, ok := <-watcher.Events:
+		case <-ctx.Done():
+			return
+		case paths, ok := <-watcher.Changes:
 			if !ok {
-				log.Print("watcher events channel closed")
-				continue forloop
+				log.Print("watcher changes channel closed")
+				return
 			}
-			filePath = event.Name
+			changedPaths = paths
 		case err, ok := <-watcher.Errors:
 			if !ok {
 				log.Print("watcher errors channel closed")
-			} else {
-				log.Printf("error from watcher: %s", err)
+				return
 			}
-			continue forloop
+			log.Printf("error from watcher: %s", err)
+			continue
 		}
 
-		// File change events are not always singular. For example, an editor that
-		// is making a change to a file could move the file to a backup location,
-		// create a new file at the path of the file it is modifying, write the
-		// new contents there, and delete the backup. All of this takes time, and
-		// we use fsnotify in such a way that we only get the first of these
-		// events, so it is possible that we start building the site before the
-		// editor (or whatever) is finished making changes. Introduce a delay to
-		// prevent this. The duration of the delay may need to be adjusted based
-		// on real-world experience.
-		time.Sleep(200 * time.Millisecond)
-
 		// rebuild
-		log.Printf("build triggered by change to %s", filePath)
-		if err := build(); err != nil {
-			log.Printf("failed to build: %s", err)
-			break forloop
+		log.Printf("build triggered by changes to %s", strings.Join(changedPaths, ", "))
+		previousBuildFailed := site.message().Type == notify.Error
+		if index := slices.IndexFunc(changedPaths, isConfigFile); index != -1 {
+			configErr = reloadConfig(watcher, changedPaths[index])
+		} else if index := slices.IndexFunc(changedPaths, isIgnoreFile); index != -1 {
+			// Directories that were ignored may need to be watched.
+			configErr = reloadConfig(watcher, changedPaths[index])
+		} else if index := slices.IndexFunc(changedPaths, isWatchedDir); index != -1 {
+			// A watched directory was created or removed, which can
+			// make the config valid or invalid.
+			configErr = reloadConfig(watcher, changedPaths[index])
+		}
+		// Building with the previous config would hide the problem.
+		if configErr != nil {
+			log.Printf("failed to reload config: %s", configErr)
+			site.fail(newBuildError(configErr))
+			notifier.Notify(site.message())
+			continue
 		}
-		if err := modifyHtmlFiles(); err != nil {
-			log.Printf("failed to modify HTML files: %s", err)
-			break forloop
+		rebuild(site)
+		message := site.message()
+		// If only stylesheets changed, pages can swap them in without
+		// reloading. But if the previous build failed, pages are showing
+		// the error and need to be reloaded to get rid of it.
+		if message.Type == notify.Reload && !previousBuildFailed {
+			if stylesheets, ok := changedStylesheets(changedPaths); ok {
+				message = notify.Message{Type: notify.CSS, Paths: stylesheets}
+			}
 		}
-		notifier.Notify()
+		notifier.Notify(message)
 	}
+}
 
-	// clean up
-	if watcher != nil {
-		watcher.Close()
+// reloadConfig reads the configuration files again after changedPath
+// changed, and makes watcher watch the directories that they specify. The
+// previous config is kept if the configuration cannot be read. If it is
+// read but is invalid, it is used anyway, so that for example the creation
+// of a missing directory is noticed, but an error is returned.
+func reloadConfig(watcher *watch.Watcher, changedPath string) error {
+	newConfigYaml, err := config.Get(configYaml.Path, configYaml.Environment, configOverrides)
+	if err != nil {
+		return &sourceFileError{
+			SourcePath: changedPath,
+			Err:        fmt.Errorf("failed to get config: %w", err),
+		}
 	}
-	stop()
+	previousConfigYaml := configYaml
+	configYaml = newConfigYaml
+	updateWatchIgnored()
+	if err := updateWatches(watcher, previousConfigYaml, configYaml); err != nil {
+		log.Println(err)
+	}
+	if err := configYaml.Validate(); err != nil {
+		return &sourceFileError{
+			SourcePath: changedPath,
+			Err:        fmt.Errorf("invalid config: %w", err),
+		}
+	}
+	return nil
 }
 
-func modifyHtmlFiles() error {
-	walkDirFunc := func(outputPath string, dirEntry fs.DirEntry, err error) error {
-		if err != nil {
+// configFiles returns the configuration files that configYaml was read
+// from, if they exist.
+func configFiles(configYaml config.ConfigYaml) []string {
+	return []string{configYaml.Path, config.EnvironmentPath(configYaml.Path, configYaml.Environment)}
+}
+
+// isConfigFile returns whether changedPath is one of the configuration
+// files.
+func isConfigFile(changedPath string) bool {
+	return slices.Contains(configFiles(configYaml), changedPath)
+}
+
+// watchIgnored is the Matcher for the files whose changes serve ignores. It
+// is read by the watcher's goroutine.
+var watchIgnored atomic.Pointer[ignore.Matcher]
+
+// updateWatchIgnored updates watchIgnored for the current config. If the
+// ignore patterns cannot be read, the previous ones are kept; the build
+// reports the error.
+func updateWatchIgnored() {
+	ignored, err := ignoreMatcher(configYaml)
+	if err != nil {
+		return
+	}
+	watchIgnored.Store(ignored)
+}
+
+// ignoreFile returns the path of the .jennyignore file for configYaml.
+func ignoreFile(configYaml config.ConfigYaml) string {
+	return filepath.Join(filepath.Dir(configYaml.Path), ignore.FileName)
+}
+
+// isIgnoreFile returns whether changedPath is the .jennyignore file.
+func isIgnoreFile(changedPath string) bool {
+	return changedPath == ignoreFile(configYaml)
+}
+
+// watchedDirs returns the directories that serve watches for changes.
+func watchedDirs(configYaml config.ConfigYaml) []string {
+	return []string{configYaml.Data, configYaml.Input, configYaml.Templates}
+}
+
+// isWatchedDir returns whether changedPath is one of the directories that
+// serve watches.
+func isWatchedDir(changedPath string) bool {
+	return slices.ContainsFunc(watchedDirs(configYaml), func(dir string) bool {
+		return filepath.Clean(dir) == changedPath
+	})
+}
+
+// updateWatches makes watcher watch the files that are used to build the
+// site with newConfigYaml instead of those for oldConfigYaml.
+func updateWatches(watcher *watch.Watcher, oldConfigYaml, newConfigYaml config.ConfigYaml) error {
+	newDirs := watchedDirs(newConfigYaml)
+	for _, dir := range watchedDirs(oldConfigYaml) {
+		if dir != "" && !slices.Contains(newDirs, dir) {
+			watcher.RemoveRecursive(dir)
+			watcher.RemoveFile(dir)
+		}
+	}
+
+	for _, configFile := range configFiles(newConfigYaml) {
+		if err := watcher.AddFile(configFile); err != nil {
+			return err
+		}
+	}
+	if err := watcher.AddFile(ignoreFile(newConfigYaml)); err != nil {
+		return err
+	}
+	for _, dir := range newDirs {
+		// Watching the directory itself means that we notice if it is
+		// created (or recreated) later.
+		if err := watcher.AddFile(dir); err != nil {
 			return err
 		}
-		if dirEntry.IsDir() {
-			return nil
+		if _, err := os.Stat(dir); errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err := watcher.AddRecursive(dir); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// changedStylesheets returns the paths in the built site of the files in
+// changedPaths. The returned bool is false if any of changedPaths is not
+// a stylesheet in the input directory, or is a fingerprinted stylesheet,
+// since pages must be reloaded to refer to its new name.
+func changedStylesheets(changedPaths []string) ([]string, bool) {
+	stylesheets := make([]string, 0, len(changedPaths))
+	for _, changedPath := range changedPaths {
+		if filepath.Ext(changedPath) != ".css" {
+			return nil, false
+		}
+		relativePath, err := filepath.Rel(configYaml.Input, changedPath)
+		if err != nil || !filepath.IsLocal(relativePath) {
+			return nil, false
 		}
-		if filepath.Ext(outputPath) != ".html" {
-			return nil
+		if asset.Match(configYaml.Fingerprint, filepath.ToSlash(relativePath)) {
+			return nil, false
 		}
+		stylesheets = append(stylesheets, filepath.ToSlash(filepath.Join("/", relativePath)))
+	}
+	return stylesheets, len(stylesheets) > 0
+}
 
-		if err := injectReloadScript(outputPath); err != nil {
-			return fmt.Errorf("failed to inject reload script into %s: %w", outputPath, err)
+// rebuild builds the site. If the build succeeds, site is updated to serve
+// the new build. Otherwise the error is recorded so that it can be shown
+// to the user.
+func rebuild(site *liveSite) {
+	builtSite, summary, err := build(minifyServed)
+	if err != nil {
+		log.Printf("failed to build: %s", err)
+		site.fail(newBuildError(err))
+		return
+	}
+	log.Print(summary)
+	site.update(builtSite)
+}
+
+// injectReloadScriptMiddleware injects the reload script into HTML
+// responses from handler. HEAD requests are served as GET requests
+// without the body, so that their headers match those of GET requests.
+func injectReloadScriptMiddleware(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet && req.Method != http.MethodHead {
+			handler.ServeHTTP(rw, req)
+			return
+		}
+		injectingWriter := &injectingResponseWriter{
+			ResponseWriter: rw,
+			head:           req.Method == http.MethodHead,
 		}
+		req = req.Clone(req.Context())
+		req.Method = http.MethodGet
+		// Injecting the script changes the offsets in the response, so
+		// serve whole files only.
+		req.Header.Del("Range")
+		handler.ServeHTTP(injectingWriter, req)
+		injectingWriter.finish()
+	})
+}
+
+// injectingResponseWriter holds back the body of HTML responses, so that
+// the reload script can be injected into them once they are complete.
+// Other responses are passed through unchanged.
+type injectingResponseWriter struct {
+	http.ResponseWriter
+	statusCode  int
+	wroteHeader bool
+	// whether the response is to a HEAD request, so that the body is
+	// not written
+	head bool
+	// nil if the response is not HTML
+	body *bytes.Buffer
+}
 
-		return nil
+func (rw *injectingResponseWriter) WriteHeader(statusCode int) {
+	if rw.wroteHeader {
+		return
 	}
+	rw.wroteHeader = true
+	rw.statusCode = statusCode
+	contentType := rw.Header().Get("Content-Type")
+	if strings.HasPrefix(contentType, "text/html") && bodyAllowedForStatus(statusCode) {
+		rw.body = &bytes.Buffer{}
+		rw.Header().Del("Content-Length")
+		return
+	}
+	rw.ResponseWriter.WriteHeader(statusCode)
+}
 
-	if err := filepath.WalkDir(configYaml.Output, walkDirFunc); err != nil {
-		return err
+func (rw *injectingResponseWriter) Write(data []byte) (int, error) {
+	if !rw.wroteHeader {
+		if rw.Header().Get("Content-Type") == "" {
+			rw.Header().Set("Content-Type", http.DetectContentType(data))
+		}
+		rw.WriteHeader(http.StatusOK)
+	}
+	if rw.body != nil {
+		return rw.body.Write(data)
 	}
+	if rw.head {
+		return len(data), nil
+	}
+	return rw.ResponseWriter.Write(data)
+}
 
-	return nil
+// finish writes the held back HTML response, if any, with the reload
+// script injected.
+func (rw *injectingResponseWriter) finish() {
+	if rw.body == nil {
+		return
+	}
+	contents := rw.body.Bytes()
+	injectedContents, err := injectReloadScript(contents)
+	if err != nil {
+		log.Printf("failed to inject reload script: %s", err)
+		injectedContents = contents
+	}
+	rw.Header().Set("Content-Length", strconv.Itoa(len(injectedContents)))
+	rw.ResponseWriter.WriteHeader(rw.statusCode)
+	if rw.head {
+		return
+	}
+	if _, err := rw.ResponseWriter.Write(injectedContents); err != nil {
+		log.Printf("failed to write response: %s", err)
+	}
+}
+
+func bodyAllowedForStatus(statusCode int) bool {
+	switch {
+	case statusCode >= 100 && statusCode <= 199:
+		return false
+	case statusCode == http.StatusNoContent, statusCode == http.StatusNotModified:
+		return false
+	}
+	return true
 }
 
-// injectScript injects the reloading script into a given .html file.
-// Does not use golang.org/x/net/html because that package converts
-// escaped HTML to the thing it represents (but only sometimes), and
-// our needs are simple.
-func injectReloadScript(filePath string) error {
+// injectReloadScript injects the reloading script into the contents of an
+// HTML file. Does not use golang.org/x/net/html because that package
+// converts escaped HTML to the thing it represents (but only sometimes),
+// and our needs are simple.
+func injectReloadScript(byteContents []byte) ([]byte, error) {
 	headEndRegex := regexp.MustCompile(`\<\/head\>`)
 	htmlOpenRegex := regexp.MustCompile(`\<html\>`)
 
-	byteContents, err := os.ReadFile(filePath)
-	if err != nil {
-		return fmt.Errorf("failed to read: %w", err)
-	}
 	contents := string(byteContents)
 
 	// Try to find the closing head element (i.e. </head>) and insert
@@ -271,10 +704,7 @@ func injectReloadScript(filePath string) error {
 		before := contents[0:loc[0]]
 		after := contents[loc[0]:]
 		newContents := before + reloadScript + after
-		if err := os.WriteFile(filePath, []byte(newContents), 0o644); err != nil {
-			return fmt.Errorf("failed to write injected file: %w", err)
-		}
-		return nil
+		return []byte(newContents), nil
 	}
 
 	// Assume that <head>...</head> does not exist in the document. Try to
@@ -285,11 +715,8 @@ func injectReloadScript(filePath string) error {
 		before := contents[0:loc[1]]
 		after := contents[loc[1]:]
 		newContents := before + `<head>` + reloadScript + `</head>` + after
-		if err := os.WriteFile(filePath, []byte(newContents), 0o644); err != nil {
-			return fmt.Errorf("failed to write injected file: %w", err)
-		}
-		return nil
+		return []byte(newContents), nil
 	}
 
-	return errors.New("failed to find index of <html> or </head>")
+	return nil, errors.New("failed to find index of <html> or </head>")
 }
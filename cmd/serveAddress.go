@@ -0,0 +1,115 @@
+package cmd
+
+import (
+	"crypto/tls"
+	"fmt"
+	"log"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/adamkpickering/jenny/internal/devcert"
+)
+
+// siteURLs returns the URLs at which a server that listens on address can
+// be reached. If address does not specify a host, the server can be
+// reached at every address of the machine, so a URL is returned for each
+// of interfaceAddrs (excluding loopback and link-local addresses) in
+// addition to a localhost URL.
+func siteURLs(scheme, address string, interfaceAddrs []net.Addr) ([]string, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse address %q: %w", address, err)
+	}
+	siteURL := func(host string) string {
+		return scheme + "://" + net.JoinHostPort(host, port) + "/"
+	}
+
+	ip := net.ParseIP(host)
+	if host != "" && (ip == nil || !ip.IsUnspecified()) {
+		return []string{siteURL(host)}, nil
+	}
+
+	urls := []string{siteURL("localhost")}
+	for _, interfaceAddr := range interfaceAddrs {
+		ipNet, ok := interfaceAddr.(*net.IPNet)
+		if !ok {
+			continue
+		}
+		interfaceIP := ipNet.IP
+		if interfaceIP.IsLoopback() || interfaceIP.IsLinkLocalUnicast() {
+			continue
+		}
+		urls = append(urls, siteURL(interfaceIP.String()))
+	}
+	return urls, nil
+}
+
+// originPatterns returns the patterns of the origins, other than the
+// host of the request, that may open a websocket. Pages served at
+// publicURL are always allowed. allowedOrigins may be hosts, host
+// patterns in the syntax of filepath.Match, or URLs.
+func originPatterns(publicURL string, allowedOrigins []string) ([]string, error) {
+	origins := allowedOrigins
+	if publicURL != "" {
+		origins = append([]string{publicURL}, origins...)
+	}
+
+	patterns := make([]string, 0, len(origins))
+	for _, origin := range origins {
+		pattern := origin
+		if strings.Contains(origin, "://") {
+			parsedOrigin, err := url.Parse(origin)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse origin %q: %w", origin, err)
+			}
+			pattern = parsedOrigin.Host
+		}
+		if pattern == "" {
+			return nil, fmt.Errorf("origin %q has no host", origin)
+		}
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid origin pattern %q: %w", pattern, err)
+		}
+		patterns = append(patterns, pattern)
+	}
+	return patterns, nil
+}
+
+// loadCertificate returns the certificate to serve over HTTPS with. If the
+// user did not provide one, a certificate that is valid for the hosts of
+// urls is loaded from (or created in) the user's config directory.
+func loadCertificate(urls []string) (tls.Certificate, error) {
+	if tlsCertPath != "" {
+		cert, err := tls.LoadX509KeyPair(tlsCertPath, tlsKeyPath)
+		if err != nil {
+			return tls.Certificate{}, fmt.Errorf("failed to load certificate: %w", err)
+		}
+		return cert, nil
+	}
+
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("failed to find config directory: %w", err)
+	}
+	certDir := filepath.Join(configDir, "jenny", "tls")
+	hosts := []string{"localhost", "127.0.0.1", "::1"}
+	for _, siteURL := range urls {
+		parsedURL, err := url.Parse(siteURL)
+		if err != nil {
+			return tls.Certificate{}, fmt.Errorf("failed to parse URL %q: %w", siteURL, err)
+		}
+		if host := parsedURL.Hostname(); !slices.Contains(hosts, host) {
+			hosts = append(hosts, host)
+		}
+	}
+	cert, err := devcert.Load(certDir, hosts)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("failed to load certificate: %w", err)
+	}
+	log.Printf("serving with a certificate signed by the local CA in %s; to avoid warnings, trust it in the browsers and devices that you use", filepath.Join(certDir, devcert.CACertFile))
+	return cert, nil
+}
@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"net"
+	"reflect"
+	"testing"
+)
+
+func TestSiteURLs(t *testing.T) {
+	interfaceAddrs := []net.Addr{
+		&net.IPNet{IP: net.ParseIP("127.0.0.1"), Mask: net.CIDRMask(8, 32)},
+		&net.IPNet{IP: net.ParseIP("192.168.1.20"), Mask: net.CIDRMask(24, 32)},
+		&net.IPNet{IP: net.ParseIP("::1"), Mask: net.CIDRMask(128, 128)},
+		&net.IPNet{IP: net.ParseIP("fe80::1"), Mask: net.CIDRMask(64, 128)},
+		&net.IPNet{IP: net.ParseIP("2001:db8::20"), Mask: net.CIDRMask(64, 128)},
+	}
+
+	t.Run("should return one URL when host is specified", func(t *testing.T) {
+		urls, err := siteURLs("http", "localhost:9023", interfaceAddrs)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		expected := []string{"http://localhost:9023/"}
+		if !reflect.DeepEqual(urls, expected) {
+			t.Errorf("got %v but expected %v", urls, expected)
+		}
+	})
+
+	t.Run("should return all network URLs when listening on all interfaces", func(t *testing.T) {
+		urls, err := siteURLs("https", "[::]:9023", interfaceAddrs)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		expected := []string{"https://localhost:9023/", "https://192.168.1.20:9023/", "https://[2001:db8::20]:9023/"}
+		if !reflect.DeepEqual(urls, expected) {
+			t.Errorf("got %v but expected %v", urls, expected)
+		}
+	})
+}
+
+func TestOriginPatterns(t *testing.T) {
+	t.Run("should return hosts of public URL and allowed origins", func(t *testing.T) {
+		patterns, err := originPatterns("https://abc.trycloudflare.com", []string{"*.local:9023", "http://192.168.1.20:9023"})
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		expected := []string{"abc.trycloudflare.com", "*.local:9023", "192.168.1.20:9023"}
+		if !reflect.DeepEqual(patterns, expected) {
+			t.Errorf("got %v but expected %v", patterns, expected)
+		}
+	})
+
+	t.Run("should return error for invalid pattern", func(t *testing.T) {
+		if _, err := originPatterns("", []string{"[.local"}); err == nil {
+			t.Errorf("did not get error when we should have")
+		}
+	})
+}
@@ -2,26 +2,23 @@ package cmd
 
 import (
 	"fmt"
-	"os"
-	"path/filepath"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
 	"testing"
+
+	"github.com/adamkpickering/jenny/internal/memfs"
 )
 
 func TestInjectReloadScript(t *testing.T) {
 	t.Run("should inject script when head element is present", func(t *testing.T) {
-		testDir := t.TempDir()
-		testFilePath := filepath.Join(testDir, "testFile.html")
 		contentTemplate := `<!DOCTYPE html><html><head><link rel="stylesheet" href="/static/style.css"/>%s</head></html>`
 		testContents := fmt.Sprintf(contentTemplate, "")
-		if err := os.WriteFile(testFilePath, []byte(testContents), 0o644); err != nil {
-			t.Fatalf("failed to write test file: %s", err)
-		}
-		if err := injectReloadScript(testFilePath); err != nil {
-			t.Fatalf("unexpected error in injectReloadScript(): %s", err)
-		}
-		newByteContents, err := os.ReadFile(testFilePath)
+		newByteContents, err := injectReloadScript([]byte(testContents))
 		if err != nil {
-			t.Fatalf("failed to read modified test file: %s", err)
+			t.Fatalf("unexpected error in injectReloadScript(): %s", err)
 		}
 		newContents := string(newByteContents)
 		expectedContents := fmt.Sprintf(contentTemplate, reloadScript)
@@ -31,19 +28,11 @@ func TestInjectReloadScript(t *testing.T) {
 	})
 
 	t.Run("should inject script when head element is not present but html element is present", func(t *testing.T) {
-		testDir := t.TempDir()
-		testFilePath := filepath.Join(testDir, "testFile.html")
 		contentTemplate := `<!DOCTYPE html><html>%s</html>`
 		testContents := fmt.Sprintf(contentTemplate, "")
-		if err := os.WriteFile(testFilePath, []byte(testContents), 0o644); err != nil {
-			t.Fatalf("failed to write test file: %s", err)
-		}
-		if err := injectReloadScript(testFilePath); err != nil {
-			t.Fatalf("unexpected error in injectReloadScript(): %s", err)
-		}
-		newByteContents, err := os.ReadFile(testFilePath)
+		newByteContents, err := injectReloadScript([]byte(testContents))
 		if err != nil {
-			t.Fatalf("failed to read modified test file: %s", err)
+			t.Fatalf("unexpected error in injectReloadScript(): %s", err)
 		}
 		newContents := string(newByteContents)
 		expectedContents := fmt.Sprintf(contentTemplate, `<head>`+reloadScript+`</head>`)
@@ -53,13 +42,8 @@ func TestInjectReloadScript(t *testing.T) {
 	})
 
 	t.Run("should return error when neither html element nor head element is present", func(t *testing.T) {
-		testDir := t.TempDir()
-		testFilePath := filepath.Join(testDir, "testFile.html")
 		testContents := `<!DOCTYPE html><body><p>here is the body but not the tags we are looking for</p></body>`
-		if err := os.WriteFile(testFilePath, []byte(testContents), 0o644); err != nil {
-			t.Fatalf("failed to write test file: %s", err)
-		}
-		err := injectReloadScript(testFilePath)
+		_, err := injectReloadScript([]byte(testContents))
 		if err == nil {
 			t.Fatalf("did not get error from injectReloadScript when we should have")
 		}
@@ -69,3 +53,110 @@ func TestInjectReloadScript(t *testing.T) {
 		}
 	})
 }
+
+func TestInjectReloadScriptMiddleware(t *testing.T) {
+	site := memfs.New()
+	htmlContents := `<!DOCTYPE html><html><head></head><body></body></html>`
+	cssContents := `body { color: red; }`
+	if err := site.WriteFile("index.html", []byte(htmlContents)); err != nil {
+		t.Fatalf("failed to write index.html: %s", err)
+	}
+	if err := site.WriteFile("style.css", []byte(cssContents)); err != nil {
+		t.Fatalf("failed to write style.css: %s", err)
+	}
+	handler := injectReloadScriptMiddleware(http.FileServerFS(site))
+
+	t.Run("should inject script into HTML responses", func(t *testing.T) {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("got status %d but expected %d", recorder.Code, http.StatusOK)
+		}
+		expected := `<!DOCTYPE html><html><head>` + reloadScript + `</head><body></body></html>`
+		if body := recorder.Body.String(); body != expected {
+			t.Errorf("got body %q but expected %q", body, expected)
+		}
+		expectedLength := strconv.Itoa(len(expected))
+		if length := recorder.Header().Get("Content-Length"); length != expectedLength {
+			t.Errorf("got Content-Length %q but expected %q", length, expectedLength)
+		}
+	})
+
+	t.Run("should not modify other responses", func(t *testing.T) {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/style.css", nil))
+		if body := recorder.Body.String(); body != cssContents {
+			t.Errorf("got body %q but expected %q", body, cssContents)
+		}
+	})
+
+	t.Run("should give HEAD requests the headers of GET requests", func(t *testing.T) {
+		for _, name := range []string{"/", "/style.css"} {
+			getRecorder := httptest.NewRecorder()
+			handler.ServeHTTP(getRecorder, httptest.NewRequest(http.MethodGet, name, nil))
+			headRecorder := httptest.NewRecorder()
+			handler.ServeHTTP(headRecorder, httptest.NewRequest(http.MethodHead, name, nil))
+			if headRecorder.Code != getRecorder.Code {
+				t.Errorf("got status %d for HEAD %s but expected %d", headRecorder.Code, name, getRecorder.Code)
+			}
+			getLength, headLength := getRecorder.Header().Get("Content-Length"), headRecorder.Header().Get("Content-Length")
+			if headLength != getLength {
+				t.Errorf("got Content-Length %q for HEAD %s but expected %q", headLength, name, getLength)
+			}
+			if headRecorder.Body.Len() != 0 {
+				t.Errorf("got body %q for HEAD %s but expected none", headRecorder.Body, name)
+			}
+		}
+	})
+
+	t.Run("should serve whole HTML file when range is requested", func(t *testing.T) {
+		recorder := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Range", "bytes=0-5")
+		handler.ServeHTTP(recorder, req)
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("got status %d but expected %d", recorder.Code, http.StatusOK)
+		}
+		if body := recorder.Body.String(); !strings.Contains(body, reloadScript) {
+			t.Errorf("got body %q which does not contain reload script", body)
+		}
+	})
+}
+
+func TestChangedStylesheets(t *testing.T) {
+	oldInput := configYaml.Input
+	t.Cleanup(func() { configYaml.Input = oldInput })
+	configYaml.Input = "input"
+
+	t.Run("should return paths in built site when only stylesheets changed", func(t *testing.T) {
+		stylesheets, ok := changedStylesheets([]string{"input/static/style.css", "input/print.css"})
+		if !ok {
+			t.Fatalf("got false but expected true")
+		}
+		expected := []string{"/static/style.css", "/print.css"}
+		if !reflect.DeepEqual(stylesheets, expected) {
+			t.Errorf("got %v but expected %v", stylesheets, expected)
+		}
+	})
+
+	t.Run("should return false when something other than a stylesheet changed", func(t *testing.T) {
+		if _, ok := changedStylesheets([]string{"input/static/style.css", "input/post1.md"}); ok {
+			t.Errorf("got true but expected false")
+		}
+	})
+
+	t.Run("should return false when stylesheet is not in input directory", func(t *testing.T) {
+		if _, ok := changedStylesheets([]string{"templates/style.css"}); ok {
+			t.Errorf("got true but expected false")
+		}
+	})
+
+	t.Run("should return false when stylesheet is fingerprinted", func(t *testing.T) {
+		oldFingerprint := configYaml.Fingerprint
+		t.Cleanup(func() { configYaml.Fingerprint = oldFingerprint })
+		configYaml.Fingerprint = []string{"static/*.css"}
+		if _, ok := changedStylesheets([]string{"input/static/style.css"}); ok {
+			t.Errorf("got true but expected false")
+		}
+	})
+}
@@ -0,0 +1,167 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/adamkpickering/jenny/internal/redirects"
+)
+
+// staticSite serves a built site the way that common static hosts do, so
+// that the site behaves the same during development as in production:
+//
+//   - a request for /post is served post.html if there is no file called
+//     post, or post/index.html if post is a directory
+//   - the rules in the _redirects file of the site are followed
+//   - requests for paths that do not exist are served the not found page
+//     of the site, if there is one, with status 404
+type staticSite struct {
+	fsys         fs.FS
+	fileServer   http.Handler
+	notFoundPage string
+	rules        []redirects.Rule
+}
+
+// newStaticSite returns a staticSite that serves fsys. notFoundPage is
+// the path in fsys of the page to serve for paths that do not exist.
+func newStaticSite(fsys fs.FS, notFoundPage string) (*staticSite, error) {
+	site := &staticSite{
+		fsys:         fsys,
+		fileServer:   http.FileServerFS(fsys),
+		notFoundPage: strings.TrimPrefix(path.Clean("/"+notFoundPage), "/"),
+	}
+
+	file, err := fsys.Open(redirects.FileName)
+	if errors.Is(err, fs.ErrNotExist) {
+		return site, nil
+	} else if err != nil {
+		return site, fmt.Errorf("failed to open %s: %w", redirects.FileName, err)
+	}
+	defer file.Close()
+	site.rules, err = redirects.Parse(file)
+	if err != nil {
+		return site, fmt.Errorf("failed to parse %s: %w", redirects.FileName, err)
+	}
+	return site, nil
+}
+
+func (site *staticSite) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	urlPath := req.URL.Path
+
+	for _, rule := range site.rules {
+		if !rule.Force {
+			continue
+		}
+		if target, ok := rule.Match(urlPath); ok {
+			site.applyRule(rw, req, rule, target)
+			return
+		}
+	}
+
+	if name, ok := site.resolve(urlPath); ok {
+		cleanName := strings.TrimPrefix(path.Clean("/"+urlPath), "/")
+		if name == cleanName || name == path.Join(cleanName, "index.html") {
+			// The file server also takes care of redirecting
+			// directories to their path with a trailing slash.
+			site.fileServer.ServeHTTP(rw, req)
+		} else {
+			site.serveFile(rw, req, name, http.StatusOK)
+		}
+		return
+	}
+
+	for _, rule := range site.rules {
+		if target, ok := rule.Match(urlPath); ok {
+			site.applyRule(rw, req, rule, target)
+			return
+		}
+	}
+
+	site.serveNotFound(rw, req)
+}
+
+// resolve returns the name of the file in the site that urlPath refers
+// to. Like common static hosts, it tries the file at urlPath, then
+// urlPath with .html appended, then index.html in the directory at
+// urlPath.
+func (site *staticSite) resolve(urlPath string) (string, bool) {
+	name := strings.TrimPrefix(path.Clean("/"+urlPath), "/")
+	if name == redirects.FileName {
+		return "", false
+	}
+	if name == "" {
+		name = "."
+	}
+	candidates := []string{name, name + ".html", path.Join(name, "index.html")}
+	for _, candidate := range candidates {
+		fileInfo, err := fs.Stat(site.fsys, candidate)
+		if err == nil && !fileInfo.IsDir() {
+			return candidate, true
+		}
+	}
+	return "", false
+}
+
+// applyRule handles a request that rule sends to target.
+func (site *staticSite) applyRule(rw http.ResponseWriter, req *http.Request, rule redirects.Rule, target string) {
+	if rule.IsRedirect() {
+		http.Redirect(rw, req, target, rule.Status)
+		return
+	}
+	targetURL, err := url.Parse(target)
+	if err != nil || targetURL.IsAbs() {
+		log.Printf("cannot serve %s in place of %s: only paths in the site are supported", target, req.URL.Path)
+		http.Error(rw, http.StatusText(http.StatusBadGateway), http.StatusBadGateway)
+		return
+	}
+	name, ok := site.resolve(targetURL.Path)
+	if !ok {
+		site.serveNotFound(rw, req)
+		return
+	}
+	site.serveFile(rw, req, name, rule.Status)
+}
+
+// serveNotFound serves the not found page of the site with status 404.
+func (site *staticSite) serveNotFound(rw http.ResponseWriter, req *http.Request) {
+	if _, err := fs.Stat(site.fsys, site.notFoundPage); err != nil {
+		http.NotFound(rw, req)
+		return
+	}
+	site.serveFile(rw, req, site.notFoundPage, http.StatusNotFound)
+}
+
+// serveFile serves the file called name in the site with the given status.
+func (site *staticSite) serveFile(rw http.ResponseWriter, req *http.Request, name string, status int) {
+	contents, err := fs.ReadFile(site.fsys, name)
+	if err != nil {
+		log.Printf("failed to read %s: %s", name, err)
+		http.Error(rw, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	if status == http.StatusOK {
+		// Supports conditional and range requests.
+		http.ServeContent(rw, req, name, time.Time{}, bytes.NewReader(contents))
+		return
+	}
+	contentType := mime.TypeByExtension(path.Ext(name))
+	if contentType == "" {
+		contentType = http.DetectContentType(contents)
+	}
+	rw.Header().Set("Content-Type", contentType)
+	rw.WriteHeader(status)
+	if req.Method != http.MethodHead {
+		if _, err := rw.Write(contents); err != nil {
+			log.Printf("failed to write: %s", err)
+		}
+	}
+}
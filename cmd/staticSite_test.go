@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/adamkpickering/jenny/internal/memfs"
+)
+
+func TestStaticSite(t *testing.T) {
+	files := map[string]string{
+		"index.html":       "home",
+		"post1.html":       "post 1",
+		"posts/index.html": "posts",
+		"404.html":         "not found",
+		"_redirects": "/old-post /post1 301\n" +
+			"/blog/* /posts/:splat 302\n" +
+			"/post1.html /posts 200!\n" +
+			"/app/* /index.html 200\n",
+	}
+	fsys := memfs.New()
+	for name, contents := range files {
+		if err := fsys.WriteFile(name, []byte(contents)); err != nil {
+			t.Fatalf("failed to write %s: %s", name, err)
+		}
+	}
+	site, err := newStaticSite(fsys, "404.html")
+	if err != nil {
+		t.Fatalf("unexpected error in newStaticSite(): %s", err)
+	}
+
+	cases := []struct {
+		description string
+		urlPath     string
+		status      int
+		body        string
+		location    string
+	}{
+		{"should redirect index.html to directory", "/index.html", http.StatusMovedPermanently, "", "./"},
+		{"should serve index of root", "/", http.StatusOK, "home", ""},
+		{"should serve html file for clean URL", "/post1", http.StatusOK, "post 1", ""},
+		{"should serve index of directory", "/posts/", http.StatusOK, "posts", ""},
+		{"should redirect directory to path with trailing slash", "/posts", http.StatusMovedPermanently, "", "posts/"},
+		{"should serve not found page", "/missing", http.StatusNotFound, "not found", ""},
+		{"should not serve redirects file", "/_redirects", http.StatusNotFound, "not found", ""},
+		{"should follow redirect rule", "/old-post", http.StatusMovedPermanently, "", "/post1"},
+		{"should follow redirect rule with splat", "/blog/2024/trip", http.StatusFound, "", "/posts/2024/trip"},
+		{"should follow forced rewrite rule for existing file", "/post1.html", http.StatusOK, "posts", ""},
+		{"should follow rewrite rule", "/app/settings", http.StatusOK, "home", ""},
+	}
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			site.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, c.urlPath, nil))
+			if recorder.Code != c.status {
+				t.Fatalf("got status %d but expected %d", recorder.Code, c.status)
+			}
+			if c.body != "" && recorder.Body.String() != c.body {
+				t.Errorf("got body %q but expected %q", recorder.Body.String(), c.body)
+			}
+			if location := recorder.Header().Get("Location"); location != c.location {
+				t.Errorf("got location %q but expected %q", location, c.location)
+			}
+		})
+	}
+}
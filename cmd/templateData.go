@@ -3,6 +3,7 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 
 	"github.com/adamkpickering/jenny/internal/content"
 	"github.com/spf13/cobra"
@@ -22,18 +23,35 @@ var templateDataCmd = &cobra.Command{
 }
 
 func runTemplateData(cmd *cobra.Command, args []string) error {
-	_, templateData, err := gatherFileInfo(configYaml)
+	ignored, err := ignoreMatcher(configYaml)
+	if err != nil {
+		return err
+	}
+	// minify files as a build does, so that fingerprinted URLs match
+	_, templateData, err := gatherFileInfo(configYaml, ignored, &minifier{mediaTypes: configYaml.Minify})
 	if err != nil {
 		return fmt.Errorf("failed to gather info on input files: %w", err)
 	}
 
+	if err := renderPages(configYaml, templateData.Pages, ignored, nil); err != nil {
+		return err
+	}
+
+	sourcePath, err := filepath.Abs(args[0])
+	if err != nil {
+		return fmt.Errorf("failed to get absolute path of %s: %w", args[0], err)
+	}
+
 	// look for the specified content file, and redact content
 	// so that the output is legible
 	foundContentFile := &content.ContentFile{}
 	for _, contentFile := range templateData.Pages {
 		contentFile.Content = "redacted for legibility"
 		contentFile.RawContent = "redacted for legibility"
-		if contentFile.SourcePath == args[0] {
+		contentFile.Summary = "redacted for legibility"
+		// compare absolute paths, so that the content file can be
+		// given as any path to it
+		if absSourcePath, err := filepath.Abs(contentFile.SourcePath); err == nil && absSourcePath == sourcePath {
 			foundContentFile = contentFile
 		}
 	}
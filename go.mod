@@ -3,9 +3,12 @@ module github.com/adamkpickering/jenny
 go 1.23.2
 
 require (
+	github.com/BurntSushi/toml v1.4.0
 	github.com/coder/websocket v1.8.12
-	github.com/fsnotify/fsnotify v1.7.0
+	github.com/disintegration/imaging v1.6.2
+	github.com/fsnotify/fsnotify v1.8.0
 	github.com/spf13/cobra v1.8.1
+	github.com/tdewolff/minify/v2 v2.24.4
 	github.com/yuin/goldmark v1.7.8
 	gopkg.in/yaml.v3 v3.0.1
 )
@@ -13,5 +16,7 @@ require (
 require (
 	github.com/inconshreveable/mousetrap v1.1.0 // indirect
 	github.com/spf13/pflag v1.0.5 // indirect
-	golang.org/x/sys v0.27.0 // indirect
+	github.com/tdewolff/parse/v2 v2.8.4 // indirect
+	golang.org/x/image v0.25.0 // indirect
+	golang.org/x/sys v0.30.0 // indirect
 )
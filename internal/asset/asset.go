@@ -0,0 +1,81 @@
+package asset
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"path"
+	"strings"
+)
+
+// The number of bytes of the hash of the contents of an asset that are
+// added to its name when it is fingerprinted.
+const fingerprintLength = 8
+
+// Asset is a non-markdown file in the input directory, which is copied to
+// the built site.
+type Asset struct {
+	// The contents of the file.
+	Contents []byte
+	// The subresource integrity hash of the contents, for use in the
+	// integrity attribute of link and script elements.
+	Integrity string
+	// The path to the file relative to the input directory, using forward
+	// slashes.
+	Name string
+	// The path to the file relative to the output directory, using
+	// forward slashes. If the asset is fingerprinted, this differs from
+	// Name.
+	SitePath string
+	// The path to the file the Asset was read from.
+	SourcePath string
+	// The URL path of the file in the built site.
+	URL string
+}
+
+// New returns the asset called name, which was read from sourcePath and
+// has contents. If fingerprint is true, a hash of contents is added to its
+// name in the built site, so that the name changes whenever the contents
+// do.
+func New(sourcePath, name string, contents []byte, fingerprint bool) *Asset {
+	hash := sha256.Sum256(contents)
+	sitePath := name
+	if fingerprint {
+		sitePath = FingerprintPath(name, hash[:])
+	}
+	return &Asset{
+		Contents:   contents,
+		Integrity:  "sha256-" + base64.StdEncoding.EncodeToString(hash[:]),
+		Name:       name,
+		SitePath:   sitePath,
+		SourcePath: sourcePath,
+		URL:        "/" + sitePath,
+	}
+}
+
+// FingerprintPath returns sitePath with hash added to the name of the
+// file, before the extension. For example, static/style.css becomes
+// static/style.0123456789abcdef.css.
+func FingerprintPath(sitePath string, hash []byte) string {
+	ext := path.Ext(sitePath)
+	fingerprint := hex.EncodeToString(hash[:min(len(hash), fingerprintLength)])
+	return strings.TrimSuffix(sitePath, ext) + "." + fingerprint + ext
+}
+
+// Match returns whether sitePath matches any of patterns. The pattern
+// syntax is that of path.Match. A pattern that does not contain a slash
+// is matched against the name of the file, so that for example *.css
+// matches every stylesheet; any other pattern is matched against the
+// whole of sitePath.
+func Match(patterns []string, sitePath string) bool {
+	for _, pattern := range patterns {
+		name := sitePath
+		if !strings.Contains(pattern, "/") {
+			name = path.Base(sitePath)
+		}
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
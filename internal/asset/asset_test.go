@@ -0,0 +1,51 @@
+package asset
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	sourcePath := filepath.Join("input", "static", "style.css")
+	contents := []byte("body {}\n")
+
+	t.Run("should keep path when not fingerprinting", func(t *testing.T) {
+		asset := New(sourcePath, "static/style.css", contents, false)
+		if asset.SitePath != "static/style.css" || asset.URL != "/static/style.css" {
+			t.Errorf("got SitePath %q and URL %q but expected the original path", asset.SitePath, asset.URL)
+		}
+		// printf "body {}\n" | openssl dgst -sha256 -binary | openssl base64 -A
+		expected := "sha256-oG/XUN5zdJg9r0ABZWSx+28haO0sV0LM9pkS6FdIA8A="
+		if asset.Integrity != expected {
+			t.Errorf("got integrity %q but expected %q", asset.Integrity, expected)
+		}
+	})
+
+	t.Run("should add hash to name when fingerprinting", func(t *testing.T) {
+		asset := New(sourcePath, "static/style.css", contents, true)
+		expected := "static/style.a06fd750de737498.css"
+		if asset.SitePath != expected || asset.URL != "/"+expected {
+			t.Errorf("got SitePath %q and URL %q but expected %q", asset.SitePath, asset.URL, expected)
+		}
+	})
+}
+
+func TestMatch(t *testing.T) {
+	cases := []struct {
+		patterns []string
+		sitePath string
+		expected bool
+	}{
+		{[]string{"*.css"}, "static/style.css", true},
+		{[]string{"*.css"}, "style.css", true},
+		{[]string{"static/*.js", "*.css"}, "static/app.js", true},
+		{[]string{"static/*.js"}, "static/vendor/app.js", false},
+		{[]string{"*.css"}, "static/app.js", false},
+		{[]string{}, "static/style.css", false},
+	}
+	for _, c := range cases {
+		if got := Match(c.patterns, c.sitePath); got != c.expected {
+			t.Errorf("got %t for %v and %s but expected %t", got, c.patterns, c.sitePath, c.expected)
+		}
+	}
+}
@@ -1,49 +1,489 @@
 package config
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"net/url"
 	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
 
+	"github.com/adamkpickering/jenny/internal/ignore"
+	"github.com/adamkpickering/jenny/internal/minify"
+	"github.com/adamkpickering/jenny/internal/redirects"
 	"gopkg.in/yaml.v3"
 )
 
-const configPath = "configuration.yaml"
+// FileName is the name of the configuration file.
+const FileName = "configuration.yaml"
+
+// The environments that the commands use by default.
+const (
+	Development = "development"
+	Production  = "production"
+)
+
+// Matches valid environment names. Environment names are used in file
+// names, so they are restricted to a safe set of characters.
+var environmentRegex = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
 
 type ConfigYaml struct {
-	Input     string `yaml:"Input"`
-	Output    string `yaml:"Output"`
-	Templates string `yaml:"Templates"`
+	AliasRedirects string `yaml:"AliasRedirects"`
+	Author         string `yaml:"Author"`
+	BaseURL        string `yaml:"BaseURL"`
+	// The directory that processed images are kept in between builds.
+	Cache     string `yaml:"Cache"`
+	Copyright string `yaml:"Copyright"`
+	Data      string `yaml:"Data"`
+	// The environment that the site is being built for, for example
+	// production or development. It cannot be set in configuration files.
+	Environment string `yaml:"Environment"`
+	// Patterns that match the non-markdown files in the input directory
+	// that get a hash of their contents added to their names.
+	Fingerprint []string `yaml:"Fingerprint"`
+	// Patterns of files in the input, templates and data directories to
+	// ignore, with the syntax of .gitignore files.
+	Ignore []string `yaml:"Ignore"`
+	// How images are processed.
+	Images ImageOptions `yaml:"Images"`
+	Input  string       `yaml:"Input"`
+	// The language of the site, such as en or en-US.
+	Language string `yaml:"Language"`
+	// Lists of links, such as the navigation links of the site, by the
+	// name of the menu.
+	Menus map[string][]MenuEntry `yaml:"Menus"`
+	// The media types of the files to minify, such as text/html.
+	Minify       []string `yaml:"Minify"`
+	NotFoundPage string   `yaml:"NotFoundPage"`
+	Output       string   `yaml:"Output"`
+	// Free-form values for use in templates.
+	Params map[string]any `yaml:"Params"`
+	// The path to the configuration file. The Cache, Data, Input, Output
+	// and Templates paths are relative to the directory that contains it.
+	Path          string `yaml:"-"`
+	SummaryLength int    `yaml:"SummaryLength"`
+	Templates     string `yaml:"Templates"`
+	Title         string `yaml:"Title"`
 }
 
-func Get() (ConfigYaml, error) {
-	configYaml := ConfigYaml{}
+// ImageOptions configures how images are processed.
+type ImageOptions struct {
+	// Whether processed JPEG images keep the EXIF metadata of the images
+	// they are processed from, such as the camera and location.
+	KeepExif bool `yaml:"KeepExif"`
+	// The quality of processed JPEG images, from 1 to 100.
+	Quality int `yaml:"Quality"`
+	// The sizes attribute of responsive images.
+	Sizes string `yaml:"Sizes"`
+	// The widths that images in markdown are resized to. If it is empty,
+	// images in markdown are left as they are.
+	Widths []int `yaml:"Widths"`
+}
+
+// A MenuEntry is a link in a menu.
+type MenuEntry struct {
+	Name string `yaml:"Name"`
+	URL  string `yaml:"URL"`
+}
+
+// An Override is a field of ConfigYaml that can be set with an environment
+// variable or a flag, which take precedence over the configuration files.
+type Override struct {
+	// The name of the field in the configuration file. Fields of
+	// mappings are named like Images.Quality.
+	Field string
+	// The name of the flag that sets the field.
+	Flag string
+	// The help text of the flag.
+	Usage string
+}
+
+// Overrides lists the fields of ConfigYaml that can be overridden, which is
+// all of them other than Environment and Path, and Menus and Params, which
+// are not single values or lists. The values of list fields are separated
+// by commas.
+var Overrides = []Override{
+	{"AliasRedirects", "alias-redirects", `also write the redirects for aliases to a file of this format: "_redirects" or ".htaccess"`},
+	{"Author", "author", "author of the site"},
+	{"BaseURL", "base-url", "absolute URL at which the site is published"},
+	{"Cache", "cache", "path to the directory that processed images are kept in"},
+	{"Copyright", "copyright", "copyright notice of the site"},
+	{"Data", "data", "path to the data directory"},
+	{"Fingerprint", "fingerprint", "comma-separated patterns of files in the input directory to fingerprint"},
+	{"Ignore", "ignore", "comma-separated patterns of files in the input, templates and data directories to ignore, relative to the directory of the configuration file"},
+	{"Images.KeepExif", "images-keep-exif", "keep the EXIF metadata of processed JPEG images"},
+	{"Images.Quality", "images-quality", "quality of processed JPEG images, from 1 to 100"},
+	{"Images.Sizes", "images-sizes", "sizes attribute of responsive images"},
+	{"Images.Widths", "images-widths", "comma-separated widths that images in markdown are resized to"},
+	{"Input", "input", "path to the input directory"},
+	{"Language", "language", "language of the site, such as en or en-US"},
+	// The flag is not called minify, which is the flag of jenny serve
+	// that turns minification on.
+	{"Minify", "minify-media-types", "comma-separated media types of the files to minify, or an empty value to minify nothing"},
+	{"NotFoundPage", "not-found-page", "path in the built site of the page that is served for missing pages"},
+	{"Output", "output", "path to the output directory"},
+	{"SummaryLength", "summary-length", "number of words in automatic page summaries"},
+	{"Templates", "templates", "path to the templates directory"},
+	{"Title", "title", "title of the site"},
+}
+
+// EnvironmentVariable returns the name of the environment variable that
+// sets the field, for example JENNY_BASE_URL for BaseURL.
+func (override Override) EnvironmentVariable() string {
+	return "JENNY_" + strings.ToUpper(strings.ReplaceAll(override.Flag, "-", "_"))
+}
+
+// Type returns the name of the type of the field, such as string or int.
+func (override Override) Type() string {
+	if fieldType := fieldType(override.Field); fieldType != nil {
+		return fieldType.String()
+	}
+	return ""
+}
+
+// fieldType returns the type of field, which is named as in Override, or
+// nil if ConfigYaml has no such field.
+func fieldType(field string) reflect.Type {
+	currentType := reflect.TypeOf(ConfigYaml{})
+	for _, name := range strings.Split(field, ".") {
+		if currentType.Kind() != reflect.Struct {
+			return nil
+		}
+		found := false
+		for i := 0; i < currentType.NumField(); i++ {
+			if currentType.Field(i).Tag.Get("yaml") == name {
+				currentType = currentType.Field(i).Type
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil
+		}
+	}
+	return currentType
+}
+
+// EnvironmentPath returns the path to the configuration file that holds
+// the overrides for environment, given the path to the base configuration
+// file. For example, the overrides for staging to configuration.yaml are
+// in configuration.staging.yaml.
+func EnvironmentPath(configPath, environment string) string {
+	base := strings.TrimSuffix(configPath, filepath.Ext(configPath))
+	return base + "." + environment + ".yaml"
+}
+
+// Find looks for the configuration file in dir and then in each of its
+// parent directories in turn, and returns the path to the first one that
+// it finds. If there is none, the path that the configuration file would
+// have in dir is returned, so that the defaults apply relative to dir.
+func Find(dir string) (string, error) {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", fmt.Errorf("failed to get absolute path of %s: %w", dir, err)
+	}
+	for searchDir := dir; ; searchDir = filepath.Join(searchDir, "..") {
+		configPath := filepath.Join(searchDir, FileName)
+		if _, err := os.Stat(configPath); err == nil {
+			return configPath, nil
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return "", fmt.Errorf("failed to stat %s: %w", configPath, err)
+		}
+		parentDir := filepath.Dir(absDir)
+		if parentDir == absDir {
+			return filepath.Join(dir, FileName), nil
+		}
+		absDir = parentDir
+	}
+}
 
-	fd, err := os.Open(configPath)
-	if !errors.Is(err, os.ErrNotExist) {
+// Get reads the configuration file at configPath for environment. The
+// configuration file for the environment, if there is one, is merged into
+// the base configuration file: mappings are merged key by key, and any
+// other value replaces the one in the base file. Relative paths in the
+// configuration files are made relative to the directory of configPath
+// instead. Last, overrides, which maps the names of fields to values,
+// replaces the values of fields. Relative paths in overrides are left
+// relative to the working directory.
+func Get(configPath, environment string, overrides map[string]string) (ConfigYaml, error) {
+	if !environmentRegex.MatchString(environment) {
+		return ConfigYaml{}, fmt.Errorf("invalid environment %q: must only contain letters, digits, - and _", environment)
+	}
+
+	merged := &yaml.Node{}
+	for _, path := range []string{configPath, EnvironmentPath(configPath, environment)} {
+		node, err := readNode(path)
 		if err != nil {
-			return ConfigYaml{}, fmt.Errorf("failed to open: %s", err)
+			return ConfigYaml{}, err
 		}
-		defer fd.Close()
-		decoder := yaml.NewDecoder(fd)
-		if err := decoder.Decode(&configYaml); err != nil {
-			return ConfigYaml{}, fmt.Errorf("failed to parse: %s", err)
+		merged = mergeNodes(merged, node)
+	}
+
+	configYaml := ConfigYaml{}
+	if !merged.IsZero() {
+		if err := merged.Decode(&configYaml); err != nil {
+			return ConfigYaml{}, fmt.Errorf("failed to parse: %w", err)
 		}
 	}
 
+	configYaml.Environment = environment
+	configYaml.Path = configPath
 	configYaml.setDefaults()
+	configYaml.resolvePaths(filepath.Dir(configPath))
+
+	for _, override := range Overrides {
+		value, ok := overrides[override.Field]
+		if !ok {
+			continue
+		}
+		if err := configYaml.set(override.Field, value); err != nil {
+			return ConfigYaml{}, fmt.Errorf("invalid value %q for %s: must be of type %s", value, override.Field, override.Type())
+		}
+	}
 
 	return configYaml, nil
 }
 
+// set sets field, which is named as in Override, to value, which is parsed
+// like a value in the configuration file would be. If field is a list,
+// value is split at commas into its elements.
+func (configYaml *ConfigYaml) set(field, value string) error {
+	valueNode := &yaml.Node{Kind: yaml.ScalarNode, Value: value}
+	if fieldType := fieldType(field); fieldType != nil && fieldType.Kind() == reflect.Slice {
+		valueNode = &yaml.Node{Kind: yaml.SequenceNode}
+		for _, element := range strings.Split(value, ",") {
+			if element = strings.TrimSpace(element); element != "" {
+				valueNode.Content = append(valueNode.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: element})
+			}
+		}
+	}
+	// Decoding a mapping into a struct only sets the fields in the
+	// mapping, so the other fields of Images are kept.
+	names := strings.Split(field, ".")
+	node := valueNode
+	for i := len(names) - 1; i >= 0; i-- {
+		node = &yaml.Node{
+			Kind: yaml.MappingNode,
+			Content: []*yaml.Node{
+				{Kind: yaml.ScalarNode, Value: names[i]},
+				node,
+			},
+		}
+	}
+	return node.Decode(configYaml)
+}
+
+// readNode reads the YAML document in the file at configPath. If the file
+// does not exist or is empty, the returned node is zero. An error is
+// returned if the document has fields that ConfigYaml does not, values of
+// the wrong type, or an Environment field.
+func readNode(configPath string) (*yaml.Node, error) {
+	document := &yaml.Node{}
+	contents, err := os.ReadFile(configPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return document, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read: %w", err)
+	}
+	if err := yaml.Unmarshal(contents, document); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", configPath, err)
+	}
+	// Decoding into a yaml.Node does not check fields, so decode again
+	// strictly. The errors give the line numbers in the file, which are
+	// lost once the files are merged.
+	decoder := yaml.NewDecoder(bytes.NewReader(contents))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&ConfigYaml{}); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("invalid %s: %w", configPath, err)
+	}
+	// unwrap the document node
+	if document.Kind == yaml.DocumentNode && len(document.Content) == 1 {
+		document = document.Content[0]
+	}
+	if document.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(document.Content); i += 2 {
+			if key := document.Content[i]; key.Value == "Environment" {
+				return nil, fmt.Errorf("invalid %s: line %d: Environment cannot be set in configuration files: it is set by --environment", configPath, key.Line)
+			}
+		}
+	}
+	return document, nil
+}
+
+// mergeNodes returns the result of merging override into base. If both are
+// mappings, the result contains the keys of both, with the values of keys
+// that are in both merged recursively. Otherwise override replaces base,
+// unless override is zero.
+func mergeNodes(base, override *yaml.Node) *yaml.Node {
+	if override.IsZero() {
+		return base
+	}
+	if base.Kind != yaml.MappingNode || override.Kind != yaml.MappingNode {
+		return override
+	}
+
+	merged := *base
+	merged.Content = append([]*yaml.Node{}, base.Content...)
+	for i := 0; i+1 < len(override.Content); i += 2 {
+		key, value := override.Content[i], override.Content[i+1]
+		found := false
+		for j := 0; j+1 < len(merged.Content); j += 2 {
+			if merged.Content[j].Value == key.Value {
+				merged.Content[j+1] = mergeNodes(merged.Content[j+1], value)
+				found = true
+				break
+			}
+		}
+		if !found {
+			merged.Content = append(merged.Content, key, value)
+		}
+	}
+	return &merged
+}
+
 func (configYaml *ConfigYaml) setDefaults() {
+	if configYaml.Cache == "" {
+		configYaml.Cache = "cache"
+	}
+	if configYaml.Data == "" {
+		configYaml.Data = "data"
+	}
+	if configYaml.Images.Quality == 0 {
+		configYaml.Images.Quality = 75
+	}
+	if configYaml.Images.Sizes == "" {
+		configYaml.Images.Sizes = "100vw"
+	}
 	if configYaml.Input == "" {
 		configYaml.Input = "input"
 	}
+	if configYaml.NotFoundPage == "" {
+		configYaml.NotFoundPage = "404.html"
+	}
 	if configYaml.Output == "" {
 		configYaml.Output = "output"
 	}
+	if configYaml.SummaryLength == 0 {
+		configYaml.SummaryLength = 70
+	}
 	if configYaml.Templates == "" {
 		configYaml.Templates = "templates"
 	}
 }
+
+// resolvePaths makes the relative filesystem paths in configYaml relative
+// to dir.
+func (configYaml *ConfigYaml) resolvePaths(dir string) {
+	for _, path := range []*string{&configYaml.Cache, &configYaml.Data, &configYaml.Input, &configYaml.Output, &configYaml.Templates} {
+		if !filepath.IsAbs(*path) {
+			*path = filepath.Join(dir, *path)
+		}
+	}
+}
+
+// Validate returns an error that describes each problem with configYaml,
+// or nil if there are none.
+func (configYaml ConfigYaml) Validate() error {
+	errs := []error{}
+	for _, dir := range []struct{ field, path string }{
+		{"Input", configYaml.Input},
+		{"Templates", configYaml.Templates},
+	} {
+		info, err := os.Stat(dir.path)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s directory %s does not exist", dir.field, dir.path))
+		} else if !info.IsDir() {
+			errs = append(errs, fmt.Errorf("%s %s is not a directory", dir.field, dir.path))
+		}
+	}
+
+	// The output directory is wiped before the site is written to it.
+	if isWithin(configYaml.Output, configYaml.Input) {
+		errs = append(errs, fmt.Errorf("Output %s must not be Input %s or inside it", configYaml.Output, configYaml.Input))
+	}
+	for _, dir := range []struct{ field, path string }{
+		{"Data", configYaml.Data},
+		{"Input", configYaml.Input},
+		{"Templates", configYaml.Templates},
+	} {
+		if isWithin(dir.path, configYaml.Output) {
+			errs = append(errs, fmt.Errorf("%s %s must not be Output %s or inside it", dir.field, dir.path, configYaml.Output))
+		}
+		// Cached images would otherwise be built, loaded or watched.
+		if isWithin(configYaml.Cache, dir.path) {
+			errs = append(errs, fmt.Errorf("Cache %s must not be %s %s or inside it", configYaml.Cache, dir.field, dir.path))
+		}
+	}
+	if isWithin(configYaml.Cache, configYaml.Output) {
+		errs = append(errs, fmt.Errorf("Cache %s must not be Output %s or inside it", configYaml.Cache, configYaml.Output))
+	}
+
+	if configYaml.BaseURL != "" {
+		baseURL, err := url.Parse(configYaml.BaseURL)
+		if err != nil || baseURL.Scheme == "" || baseURL.Host == "" {
+			errs = append(errs, fmt.Errorf("BaseURL %q must be an absolute URL, such as https://example.com/", configYaml.BaseURL))
+		}
+	}
+	for _, pattern := range configYaml.Fingerprint {
+		if _, err := path.Match(pattern, ""); err != nil {
+			errs = append(errs, fmt.Errorf("Fingerprint pattern %q is invalid: %w", pattern, err))
+		}
+	}
+	for _, pattern := range configYaml.Ignore {
+		if _, err := ignore.New("", []string{pattern}); err != nil {
+			errs = append(errs, fmt.Errorf("Ignore: %w", err))
+		}
+	}
+	if configYaml.Images.Quality < 1 || configYaml.Images.Quality > 100 {
+		errs = append(errs, fmt.Errorf("Images.Quality %d must be from 1 to 100", configYaml.Images.Quality))
+	}
+	for _, width := range configYaml.Images.Widths {
+		if width < 1 {
+			errs = append(errs, fmt.Errorf("Images.Widths must be positive, not %d", width))
+		}
+	}
+	for _, name := range slices.Sorted(maps.Keys(configYaml.Menus)) {
+		for i, entry := range configYaml.Menus[name] {
+			if entry.Name == "" || entry.URL == "" {
+				errs = append(errs, fmt.Errorf("entry %d of menu %s must have a Name and a URL", i+1, name))
+			}
+		}
+	}
+	for _, mediaType := range configYaml.Minify {
+		if !slices.Contains(minify.MediaTypes, mediaType) {
+			errs = append(errs, fmt.Errorf("Minify media type %q must be one of %s", mediaType, strings.Join(minify.MediaTypes, ", ")))
+		}
+	}
+	switch configYaml.AliasRedirects {
+	case "", redirects.FileName, ".htaccess":
+	default:
+		errs = append(errs, fmt.Errorf("AliasRedirects %q must be %q or %q", configYaml.AliasRedirects, redirects.FileName, ".htaccess"))
+	}
+	if configYaml.SummaryLength < 0 {
+		errs = append(errs, fmt.Errorf("SummaryLength %d must not be negative", configYaml.SummaryLength))
+	}
+
+	return errors.Join(errs...)
+}
+
+// isWithin returns whether path is dir or inside it.
+func isWithin(path, dir string) bool {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return false
+	}
+	relativePath, err := filepath.Rel(absDir, absPath)
+	return err == nil && filepath.IsLocal(relativePath)
+}
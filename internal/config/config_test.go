@@ -0,0 +1,381 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+// chdir changes into a new temporary directory that contains files, and
+// changes back once the test is done.
+func chdir(t *testing.T, files map[string]string) {
+	t.Helper()
+	dir := t.TempDir()
+	for name, contents := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("failed to create directory for %s: %s", name, err)
+		}
+		if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+			t.Fatalf("failed to write %s: %s", name, err)
+		}
+	}
+	previousDir, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("failed to get working directory: %s", err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatalf("failed to change directory: %s", err)
+	}
+	t.Cleanup(func() {
+		if err := os.Chdir(previousDir); err != nil {
+			t.Fatalf("failed to change back to %s: %s", previousDir, err)
+		}
+	})
+}
+
+func TestGet(t *testing.T) {
+	t.Run("should use defaults when there is no configuration file", func(t *testing.T) {
+		chdir(t, map[string]string{})
+		configYaml, err := Get(FileName, Production, nil)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if configYaml.Input != "input" || configYaml.SummaryLength != 70 {
+			t.Errorf("got %+v but expected defaults", configYaml)
+		}
+		if configYaml.Environment != Production {
+			t.Errorf("got environment %q but expected %q", configYaml.Environment, Production)
+		}
+	})
+
+	t.Run("should merge environment file into base file", func(t *testing.T) {
+		chdir(t, map[string]string{
+			"configuration.yaml":            "Input: content\nOutput: public\nSummaryLength: 50\n",
+			"configuration.staging.yaml":    "Output: staging\n",
+			"configuration.production.yaml": "Output: production\n",
+		})
+		configYaml, err := Get(FileName, "staging", nil)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if configYaml.Input != "content" || configYaml.SummaryLength != 50 {
+			t.Errorf("got %+v but expected values from base file", configYaml)
+		}
+		if configYaml.Output != "staging" {
+			t.Errorf("got Output %q but expected %q", configYaml.Output, "staging")
+		}
+		if configYaml.Environment != "staging" {
+			t.Errorf("got environment %q but expected %q", configYaml.Environment, "staging")
+		}
+	})
+
+	t.Run("should reject environment in configuration file", func(t *testing.T) {
+		chdir(t, map[string]string{
+			"configuration.yaml":             "Title: Site\n",
+			"configuration.development.yaml": "Title: Dev\nEnvironment: production\n",
+		})
+		_, err := Get(FileName, Development, nil)
+		if err == nil {
+			t.Fatalf("did not get error when we should have")
+		}
+		if expected := "configuration.development.yaml: line 2: Environment cannot be set"; !strings.Contains(err.Error(), expected) {
+			t.Errorf("got error %q which does not contain %q", err, expected)
+		}
+	})
+
+	t.Run("should resolve paths relative to configuration file", func(t *testing.T) {
+		chdir(t, map[string]string{
+			"site/configuration.yaml":         "Input: content\n",
+			"site/configuration.staging.yaml": "Output: /srv/www\n",
+		})
+		configYaml, err := Get(filepath.Join("site", FileName), "staging", nil)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		expected := []string{filepath.Join("site", "data"), filepath.Join("site", "content"), "/srv/www", filepath.Join("site", "templates")}
+		got := []string{configYaml.Data, configYaml.Input, configYaml.Output, configYaml.Templates}
+		if !reflect.DeepEqual(got, expected) {
+			t.Errorf("got %v but expected %v", got, expected)
+		}
+	})
+
+	t.Run("should apply overrides after configuration files", func(t *testing.T) {
+		chdir(t, map[string]string{
+			"site/configuration.yaml": "Output: public\nSummaryLength: 50\nBaseURL: https://example.com/\n",
+		})
+		overrides := map[string]string{"Output": "build", "SummaryLength": "20"}
+		configYaml, err := Get(filepath.Join("site", FileName), Production, overrides)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if configYaml.Output != "build" {
+			t.Errorf("got Output %q but expected %q", configYaml.Output, "build")
+		}
+		if configYaml.SummaryLength != 20 {
+			t.Errorf("got SummaryLength %d but expected %d", configYaml.SummaryLength, 20)
+		}
+		if configYaml.BaseURL != "https://example.com/" {
+			t.Errorf("got BaseURL %q but expected value from file", configYaml.BaseURL)
+		}
+	})
+
+	t.Run("should split overrides of lists at commas", func(t *testing.T) {
+		chdir(t, map[string]string{
+			"configuration.yaml": "Fingerprint:\n  - static/*.css\n",
+		})
+		configYaml, err := Get(FileName, Production, map[string]string{"Fingerprint": "static/*.js, *.svg"})
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if expected := []string{"static/*.js", "*.svg"}; !reflect.DeepEqual(configYaml.Fingerprint, expected) {
+			t.Errorf("got Fingerprint %v but expected %v", configYaml.Fingerprint, expected)
+		}
+	})
+
+	t.Run("should override fields of mappings", func(t *testing.T) {
+		chdir(t, map[string]string{
+			"configuration.yaml": "Images:\n  Sizes: 50vw\n  Widths: [400]\n",
+		})
+		overrides := map[string]string{"Images.Quality": "90", "Images.KeepExif": "true", "Images.Widths": "200,800"}
+		configYaml, err := Get(FileName, Production, overrides)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		expected := ImageOptions{KeepExif: true, Quality: 90, Sizes: "50vw", Widths: []int{200, 800}}
+		if !reflect.DeepEqual(configYaml.Images, expected) {
+			t.Errorf("got Images %+v but expected %+v", configYaml.Images, expected)
+		}
+	})
+
+	t.Run("should return error for override of wrong type", func(t *testing.T) {
+		chdir(t, map[string]string{})
+		if _, err := Get(FileName, Production, map[string]string{"SummaryLength": "many"}); err == nil {
+			t.Errorf("did not get error when we should have")
+		}
+	})
+
+	t.Run("should read site fields, params and menus", func(t *testing.T) {
+		chdir(t, map[string]string{
+			"configuration.yaml": "Title: Example Site\n" +
+				"Params:\n  Analytics: abc\n  Social:\n    Mastodon: example\n" +
+				"Menus:\n  main:\n    - Name: Home\n      URL: /\n",
+			"configuration.production.yaml": "Params:\n  Analytics: xyz\n",
+		})
+		configYaml, err := Get(FileName, Production, nil)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if configYaml.Title != "Example Site" {
+			t.Errorf("got Title %q but expected %q", configYaml.Title, "Example Site")
+		}
+		expectedParams := map[string]any{"Analytics": "xyz", "Social": map[string]any{"Mastodon": "example"}}
+		if !reflect.DeepEqual(configYaml.Params, expectedParams) {
+			t.Errorf("got Params %v but expected %v", configYaml.Params, expectedParams)
+		}
+		expectedMenus := map[string][]MenuEntry{"main": {{Name: "Home", URL: "/"}}}
+		if !reflect.DeepEqual(configYaml.Menus, expectedMenus) {
+			t.Errorf("got Menus %v but expected %v", configYaml.Menus, expectedMenus)
+		}
+	})
+
+	t.Run("should return error with line number for unknown field", func(t *testing.T) {
+		chdir(t, map[string]string{
+			"configuration.yaml": "Input: content\nOuput: public\n",
+		})
+		_, err := Get(FileName, Production, nil)
+		if err == nil {
+			t.Fatalf("did not get error when we should have")
+		}
+		if !strings.Contains(err.Error(), "line 2: field Ouput not found") {
+			t.Errorf("got error %q but expected it to mention line 2 and Ouput", err)
+		}
+	})
+
+	t.Run("should return error for invalid environment", func(t *testing.T) {
+		chdir(t, map[string]string{})
+		if _, err := Get(FileName, "../secrets", nil); err == nil {
+			t.Errorf("did not get error when we should have")
+		}
+	})
+}
+
+func TestOverrides(t *testing.T) {
+	t.Run("should have an override for each field", func(t *testing.T) {
+		fields := []string{}
+		configYamlType := reflect.TypeOf(ConfigYaml{})
+		for i := 0; i < configYamlType.NumField(); i++ {
+			field := configYamlType.Field(i)
+			name := field.Tag.Get("yaml")
+			if field.Type.Kind() != reflect.Struct {
+				fields = append(fields, name)
+				continue
+			}
+			for j := 0; j < field.Type.NumField(); j++ {
+				fields = append(fields, name+"."+field.Type.Field(j).Tag.Get("yaml"))
+			}
+		}
+		for _, field := range fields {
+			if field == "Environment" || field == "Menus" || field == "Params" || field == "-" {
+				continue
+			}
+			found := false
+			for _, override := range Overrides {
+				if override.Field == field {
+					found = true
+				}
+			}
+			if !found {
+				t.Errorf("there is no override for %s", field)
+			}
+		}
+	})
+
+	t.Run("should give the types of fields of mappings", func(t *testing.T) {
+		override := Override{Field: "Images.Widths"}
+		if got := override.Type(); got != "[]int" {
+			t.Errorf("got %q but expected %q", got, "[]int")
+		}
+	})
+
+	t.Run("should derive environment variable from flag", func(t *testing.T) {
+		override := Override{Field: "BaseURL", Flag: "base-url"}
+		if got := override.EnvironmentVariable(); got != "JENNY_BASE_URL" {
+			t.Errorf("got %q but expected %q", got, "JENNY_BASE_URL")
+		}
+	})
+}
+
+func TestEnvironmentPath(t *testing.T) {
+	cases := []struct {
+		configPath string
+		expected   string
+	}{
+		{"configuration.yaml", "configuration.staging.yaml"},
+		{filepath.Join("site", "configuration.yaml"), filepath.Join("site", "configuration.staging.yaml")},
+		{"site.yml", "site.staging.yaml"},
+	}
+	for _, c := range cases {
+		if got := EnvironmentPath(c.configPath, "staging"); got != c.expected {
+			t.Errorf("got %q but expected %q", got, c.expected)
+		}
+	}
+}
+
+func TestFind(t *testing.T) {
+	t.Run("should find configuration file in parent directory", func(t *testing.T) {
+		chdir(t, map[string]string{
+			"configuration.yaml": "",
+			"input/posts/a.md":   "",
+		})
+		if err := os.Chdir(filepath.Join("input", "posts")); err != nil {
+			t.Fatalf("failed to change directory: %s", err)
+		}
+		configPath, err := Find(".")
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		expected := filepath.Join("..", "..", FileName)
+		if configPath != expected {
+			t.Errorf("got %q but expected %q", configPath, expected)
+		}
+	})
+
+	t.Run("should find configuration file in directory", func(t *testing.T) {
+		chdir(t, map[string]string{
+			"configuration.yaml":       "",
+			"input/configuration.yaml": "",
+		})
+		configPath, err := Find("input")
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		expected := filepath.Join("input", FileName)
+		if configPath != expected {
+			t.Errorf("got %q but expected %q", configPath, expected)
+		}
+	})
+
+	t.Run("should fall back to directory when there is no configuration file", func(t *testing.T) {
+		chdir(t, map[string]string{})
+		configPath, err := Find(".")
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if configPath != FileName {
+			t.Errorf("got %q but expected %q", configPath, FileName)
+		}
+	})
+}
+
+func TestValidate(t *testing.T) {
+	cases := []struct {
+		description string
+		configYaml  ConfigYaml
+		errorText   string
+	}{
+		{"should accept valid config", ConfigYaml{Cache: "cache", Data: "data", Images: ImageOptions{Quality: 75}, Input: "input", Output: "output", Templates: "templates"}, ""},
+		{"should reject missing input directory", ConfigYaml{Input: "missing", Output: "output", Templates: "templates"}, "Input directory missing does not exist"},
+		{"should reject input that is a file", ConfigYaml{Input: "file", Output: "output", Templates: "templates"}, "Input file is not a directory"},
+		{"should reject output equal to input", ConfigYaml{Input: "input", Output: "input", Templates: "templates"}, "must not be Input"},
+		{"should reject output inside input", ConfigYaml{Input: "input", Output: "input/output", Templates: "templates"}, "must not be Input"},
+		{"should reject input inside output", ConfigYaml{Input: "input", Output: ".", Templates: "templates"}, "Input input must not be Output"},
+		{"should reject cache inside input", ConfigYaml{Cache: "input/cache", Input: "input", Output: "output", Templates: "templates"}, "Cache input/cache must not be Input"},
+		{"should reject cache inside output", ConfigYaml{Cache: "output/cache", Input: "input", Output: "output", Templates: "templates"}, "Cache output/cache must not be Output"},
+		{"should reject relative base URL", ConfigYaml{BaseURL: "example.com", Input: "input", Output: "output", Templates: "templates"}, "BaseURL"},
+		{"should reject invalid fingerprint pattern", ConfigYaml{Fingerprint: []string{"static/["}, Input: "input", Output: "output", Templates: "templates"}, "Fingerprint pattern"},
+		{"should reject unknown minify media type", ConfigYaml{Input: "input", Minify: []string{"image/png"}, Output: "output", Templates: "templates"}, "Minify media type"},
+		{"should reject invalid ignore pattern", ConfigYaml{Ignore: []string{"static/["}, Input: "input", Output: "output", Templates: "templates"}, `Ignore: invalid pattern "static/["`},
+		{"should reject out of range image quality", ConfigYaml{Images: ImageOptions{Quality: 101}, Input: "input", Output: "output", Templates: "templates"}, "Images.Quality 101"},
+		{"should reject non-positive image width", ConfigYaml{Images: ImageOptions{Widths: []int{0}}, Input: "input", Output: "output", Templates: "templates"}, "Images.Widths"},
+		{"should reject menu entry without URL", ConfigYaml{Input: "input", Menus: map[string][]MenuEntry{"main": {{Name: "Home"}}}, Output: "output", Templates: "templates"}, "entry 1 of menu main"},
+		{"should reject unknown alias redirects", ConfigYaml{AliasRedirects: "nginx.conf", Input: "input", Output: "output", Templates: "templates"}, "AliasRedirects"},
+	}
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			chdir(t, map[string]string{
+				"input/index.md":     "",
+				"templates/a.gotmpl": "",
+				"file":               "",
+			})
+			err := c.configYaml.Validate()
+			if c.errorText == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %s", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), c.errorText) {
+				t.Errorf("got error %v but expected it to contain %q", err, c.errorText)
+			}
+		})
+	}
+}
+
+func TestMergeNodes(t *testing.T) {
+	t.Run("should merge nested mappings and replace other values", func(t *testing.T) {
+		base := &yaml.Node{}
+		if err := yaml.Unmarshal([]byte("a:\n  b: 1\n  c: [1, 2]\nd: x\n"), base); err != nil {
+			t.Fatalf("failed to parse base: %s", err)
+		}
+		override := &yaml.Node{}
+		if err := yaml.Unmarshal([]byte("a:\n  c: [3]\n  e: 4\n"), override); err != nil {
+			t.Fatalf("failed to parse override: %s", err)
+		}
+		merged := map[string]any{}
+		if err := mergeNodes(base.Content[0], override.Content[0]).Decode(&merged); err != nil {
+			t.Fatalf("failed to decode merged node: %s", err)
+		}
+		expected := map[string]any{
+			"a": map[string]any{"b": 1, "c": []any{3}, "e": 4},
+			"d": "x",
+		}
+		if !reflect.DeepEqual(merged, expected) {
+			t.Errorf("got %v but expected %v", merged, expected)
+		}
+	})
+}
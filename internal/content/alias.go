@@ -0,0 +1,52 @@
+package content
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// AliasPaths returns the paths, relative to the output directory and using
+// forward slashes, of the pages that redirect from the aliases of the
+// content file to it. Path must already be set.
+//
+// An alias that starts with / is relative to the root of the site, and
+// any other alias is relative to the directory of Path. An alias that
+// ends with .html or .htm is used as is, and index.html is added to any
+// other alias, so that for example the alias /old-post/ (or /old-post)
+// becomes old-post/index.html.
+func (contentFile *ContentFile) AliasPaths() ([]string, error) {
+	aliasPaths := make([]string, 0, len(contentFile.Metadata.Aliases))
+	pageDir := strings.TrimPrefix(path.Dir(filepath.ToSlash(contentFile.Path)), "/")
+	for _, alias := range contentFile.Metadata.Aliases {
+		if alias == "" {
+			return nil, fmt.Errorf("alias must not be empty")
+		}
+		var aliasPath string
+		if strings.HasPrefix(alias, "/") {
+			aliasPath = strings.TrimPrefix(path.Clean(alias), "/")
+		} else {
+			aliasPath = path.Join(pageDir, alias)
+			if aliasPath == ".." || strings.HasPrefix(aliasPath, "../") {
+				return nil, fmt.Errorf("alias %q is outside of the site", alias)
+			}
+		}
+		if ext := path.Ext(aliasPath); ext != ".html" && ext != ".htm" {
+			aliasPath = path.Join(aliasPath, "index.html")
+		}
+		aliasPaths = append(aliasPaths, aliasPath)
+	}
+	return aliasPaths, nil
+}
+
+// URLPath returns the path of the URL at which a page at sitePath (relative
+// to the output directory, using forward slashes) is served. index.html is
+// left out, so that for example posts/index.html is served at /posts/.
+func URLPath(sitePath string) string {
+	urlPath := "/" + strings.TrimPrefix(sitePath, "/")
+	if path.Base(urlPath) == "index.html" {
+		return strings.TrimSuffix(urlPath, "index.html")
+	}
+	return urlPath
+}
@@ -0,0 +1,53 @@
+package content
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAliasPaths(t *testing.T) {
+	t.Run("should resolve aliases relative to site root and page directory", func(t *testing.T) {
+		contentFile := &ContentFile{
+			Path: "/posts/new-name.html",
+			Metadata: ContentMetadata{
+				Aliases: []string{"/old-name", "/2023/trip/", "old-name.html", "../legacy"},
+			},
+		}
+		aliasPaths, err := contentFile.AliasPaths()
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		expected := []string{"old-name/index.html", "2023/trip/index.html", "posts/old-name.html", "legacy/index.html"}
+		if !reflect.DeepEqual(aliasPaths, expected) {
+			t.Errorf("got %v but expected %v", aliasPaths, expected)
+		}
+	})
+
+	t.Run("should return error for alias outside of site", func(t *testing.T) {
+		contentFile := &ContentFile{
+			Path: "/posts/new-name.html",
+			Metadata: ContentMetadata{
+				Aliases: []string{"../../old-name"},
+			},
+		}
+		if _, err := contentFile.AliasPaths(); err == nil {
+			t.Errorf("did not get error when we should have")
+		}
+	})
+}
+
+func TestURLPath(t *testing.T) {
+	cases := map[string]string{
+		"index.html":       "/",
+		"posts/index.html": "/posts/",
+		"post1.html":       "/post1.html",
+		"/post1.html":      "/post1.html",
+	}
+	for sitePath, expected := range cases {
+		t.Run("should return "+expected+" for "+sitePath, func(t *testing.T) {
+			if urlPath := URLPath(sitePath); urlPath != expected {
+				t.Errorf("got %q but expected %q", urlPath, expected)
+			}
+		})
+	}
+}
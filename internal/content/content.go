@@ -13,19 +13,43 @@ import (
 // Represents a markdown file with a YAML header containing metadata about that
 // file.
 type ContentFile struct {
+	// The pages above this one in the section tree, starting at the top.
+	Ancestors []*ContentFile `yaml:"-"`
+	// The pages whose Parent is this one. For the page of a section, these
+	// are the pages in the section and the pages of its subsections, along
+	// with the pages of any sections below it that have no page.
+	Children []*ContentFile `yaml:"-"`
 	// The built (i.e. HTML) content.
 	Content string `yaml:"Content"`
 	// The contents of the yaml header.
 	Metadata ContentMetadata `yaml:"Metadata"`
+	// The page of the closest section above this one that has a page.
+	Parent *ContentFile `yaml:"-"`
 	// The path to the built content file relative to the output directory.
 	Path string `yaml:"Path"`
 	// The markdown content of the file from below the yaml header.
 	RawContent string `yaml:"RawContent"`
+	// The estimated number of minutes it takes to read Content.
+	ReadingTime int `yaml:"ReadingTime"`
+	// The non-markdown files in the page bundle of this content file, if
+	// it is the index of a page bundle.
+	Resources Resources `yaml:"Resources,omitempty"`
+	// The section that this content file is in. For the page of a
+	// section, this is that section.
+	Section *Section `yaml:"-"`
 	// The path to the file the Content struct was built from.
 	SourcePath string `yaml:"SourcePath"`
+	// A teaser for the content, in HTML. Either everything above the
+	// summary divider, or the first words of Content.
+	Summary string `yaml:"Summary"`
+	// Whether Summary is shorter than Content.
+	Truncated bool `yaml:"Truncated"`
+	// The number of words in Content.
+	WordCount int `yaml:"WordCount"`
 }
 
 type ContentMetadata struct {
+	Aliases      []string  `yaml:"Aliases,omitempty"`
 	LastModified time.Time `yaml:"LastModified,omitempty"`
 	Published    time.Time `yaml:"Published,omitempty"`
 	TemplateName string    `yaml:"TemplateName,omitempty"`
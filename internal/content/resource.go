@@ -0,0 +1,98 @@
+package content
+
+import (
+	"mime"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// BundleIndex is the name of the content file that makes the directory
+// it is in a page bundle. All non-markdown files in a page bundle are
+// resources of the bundle's page.
+const BundleIndex = "index.md"
+
+// Resource is a non-markdown file that belongs to a page bundle.
+type Resource struct {
+	// The media type of the file, for example image/jpeg.
+	MediaType string `yaml:"MediaType"`
+	// The path to the file relative to the directory of the page bundle,
+	// using forward slashes.
+	Name string `yaml:"Name"`
+	// The path to the file relative to the output directory.
+	Path string `yaml:"Path"`
+	// The size of the file in the built site in bytes, which is smaller
+	// than the source file if the file is minified.
+	Size int64 `yaml:"Size"`
+	// The path to the file the Resource was created from.
+	SourcePath string `yaml:"SourcePath"`
+}
+
+// Resources is a list of resources that supports filtering in templates.
+type Resources []*Resource
+
+// NewResource returns a Resource called name for the file at sourcePath,
+// which has the path outputPath and is size bytes long in the built site.
+func NewResource(name, sourcePath, outputPath string, size int64) *Resource {
+	return &Resource{
+		MediaType:  mediaType(sourcePath),
+		Name:       name,
+		Path:       outputPath,
+		Size:       size,
+		SourcePath: sourcePath,
+	}
+}
+
+// Match returns the resources whose Name matches pattern. The pattern
+// syntax is that of path.Match.
+func (resources Resources) Match(pattern string) Resources {
+	matches := Resources{}
+	for _, resource := range resources {
+		if ok, _ := path.Match(pattern, resource.Name); ok {
+			matches = append(matches, resource)
+		}
+	}
+	return matches
+}
+
+// GetMatch returns the first resource whose Name matches pattern, or nil
+// if there is none.
+func (resources Resources) GetMatch(pattern string) *Resource {
+	matches := resources.Match(pattern)
+	if len(matches) == 0 {
+		return nil
+	}
+	return matches[0]
+}
+
+// ByType returns the resources whose media type is mediaType. mediaType
+// may also be just the main type, for example "image".
+func (resources Resources) ByType(mediaType string) Resources {
+	matches := Resources{}
+	for _, resource := range resources {
+		mainType, _, _ := strings.Cut(resource.MediaType, "/")
+		if resource.MediaType == mediaType || mainType == mediaType {
+			matches = append(matches, resource)
+		}
+	}
+	return matches
+}
+
+// Get returns the resource with the given Name, or nil if there is none.
+func (resources Resources) Get(name string) *Resource {
+	for _, resource := range resources {
+		if resource.Name == name {
+			return resource
+		}
+	}
+	return nil
+}
+
+func mediaType(filePath string) string {
+	mediaType := mime.TypeByExtension(filepath.Ext(filePath))
+	if mediaType == "" {
+		return "application/octet-stream"
+	}
+	mediaType, _, _ = strings.Cut(mediaType, ";")
+	return mediaType
+}
@@ -0,0 +1,38 @@
+package content
+
+import "testing"
+
+func TestResources(t *testing.T) {
+	resources := Resources{
+		{Name: "photo1.jpg", MediaType: "image/jpeg"},
+		{Name: "photo2.png", MediaType: "image/png"},
+		{Name: "notes.txt", MediaType: "text/plain"},
+		{Name: "maps/route.pdf", MediaType: "application/pdf"},
+	}
+
+	t.Run("Match should match names by glob", func(t *testing.T) {
+		matches := resources.Match("photo*")
+		if len(matches) != 2 || matches[0].Name != "photo1.jpg" || matches[1].Name != "photo2.png" {
+			t.Errorf("got unexpected matches %v", matches)
+		}
+		matches = resources.Match("maps/*.pdf")
+		if len(matches) != 1 || matches[0].Name != "maps/route.pdf" {
+			t.Errorf("got unexpected matches %v", matches)
+		}
+	})
+
+	t.Run("GetMatch should return nil when nothing matches", func(t *testing.T) {
+		if resource := resources.GetMatch("*.gif"); resource != nil {
+			t.Errorf("got %v but expected nil", resource)
+		}
+	})
+
+	t.Run("ByType should match main type and full media type", func(t *testing.T) {
+		if matches := resources.ByType("image"); len(matches) != 2 {
+			t.Errorf("got %d images but expected 2", len(matches))
+		}
+		if matches := resources.ByType("image/png"); len(matches) != 1 {
+			t.Errorf("got %d PNG images but expected 1", len(matches))
+		}
+	})
+}
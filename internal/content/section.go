@@ -0,0 +1,160 @@
+package content
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+// SectionIndex is the name of the content file that provides the page for
+// the section of the directory it is in.
+const SectionIndex = "_index.md"
+
+// Section is a directory in the input directory that contains pages. Page
+// bundles and their subdirectories are not sections.
+type Section struct {
+	// The path to the directory of the section relative to the input
+	// directory, using forward slashes. The root section has Dir ".".
+	Dir string
+	// The title of Page, or the name of Dir if there is no Page or it
+	// has no title. The root section has no default title.
+	Title string
+	// The page built from the _index.md of the section, if it has one.
+	Page *ContentFile
+	// The pages in the section, not including Page or pages in Sections.
+	Pages []*ContentFile
+	// The section that contains this section. Nil for the root section.
+	Parent *Section
+	// The sections that this section contains.
+	Sections []*Section
+}
+
+// sectionYaml is how a Section is represented in YAML. Pages are referred to
+// by path, since the relationships between pages and sections are cyclic.
+type sectionYaml struct {
+	Dir      string     `yaml:"Dir"`
+	Title    string     `yaml:"Title"`
+	Page     string     `yaml:"Page,omitempty"`
+	Pages    []string   `yaml:"Pages,omitempty"`
+	Sections []*Section `yaml:"Sections,omitempty"`
+}
+
+func (section *Section) MarshalYAML() (any, error) {
+	out := sectionYaml{
+		Dir:      section.Dir,
+		Title:    section.Title,
+		Sections: section.Sections,
+	}
+	if section.Page != nil {
+		out.Page = section.Page.Path
+	}
+	for _, contentFile := range section.Pages {
+		out.Pages = append(out.Pages, contentFile.Path)
+	}
+	return out, nil
+}
+
+// BuildSections arranges pages into a tree of sections based on where
+// their source files are in inputDir, and sets the Section, Parent,
+// Children and Ancestors fields of each page. It returns the root section.
+func BuildSections(inputDir string, pages []*ContentFile) (*Section, error) {
+	relativeDirs := make(map[*ContentFile]string, len(pages))
+	bundleDirs := map[string]bool{}
+	for _, contentFile := range pages {
+		relativePath, err := filepath.Rel(inputDir, contentFile.SourcePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get path of %s relative to %s: %w", contentFile.SourcePath, inputDir, err)
+		}
+		relativeDir := filepath.Dir(relativePath)
+		relativeDirs[contentFile] = relativeDir
+		if filepath.Base(relativePath) == BundleIndex && relativeDir != "." {
+			bundleDirs[relativeDir] = true
+		}
+	}
+
+	root := &Section{Dir: "."}
+	sections := map[string]*Section{".": root}
+	var getSection func(dir string) *Section
+	getSection = func(dir string) *Section {
+		if section, ok := sections[dir]; ok {
+			return section
+		}
+		parent := getSection(filepath.Dir(dir))
+		section := &Section{
+			Dir:    filepath.ToSlash(dir),
+			Parent: parent,
+		}
+		parent.Sections = append(parent.Sections, section)
+		sections[dir] = section
+		return section
+	}
+
+	for _, contentFile := range pages {
+		// Pages in page bundles belong to the section that contains
+		// the outermost bundle.
+		sectionDir := relativeDirs[contentFile]
+		inBundle := false
+		for dir := sectionDir; dir != "."; dir = filepath.Dir(dir) {
+			if bundleDirs[dir] {
+				sectionDir = filepath.Dir(dir)
+				inBundle = true
+			}
+		}
+
+		section := getSection(sectionDir)
+		contentFile.Section = section
+		if filepath.Base(contentFile.SourcePath) == SectionIndex && !inBundle {
+			if section.Page != nil {
+				return nil, fmt.Errorf("section %s has more than one %s", section.Dir, SectionIndex)
+			}
+			section.Page = contentFile
+		} else {
+			section.Pages = append(section.Pages, contentFile)
+		}
+	}
+
+	linkSection(root)
+
+	return root, nil
+}
+
+// linkSection sets the fields of section and the pages in it (including
+// those of subsections) that depend on the structure of the tree.
+func linkSection(section *Section) {
+	if section.Parent != nil {
+		section.Title = filepath.Base(section.Dir)
+	}
+	if section.Page != nil {
+		if section.Page.Metadata.Title != "" {
+			section.Title = section.Page.Metadata.Title
+		}
+		if section.Parent != nil {
+			setParent(section.Page, section.Parent)
+		}
+	}
+
+	for _, contentFile := range section.Pages {
+		setParent(contentFile, section)
+	}
+
+	for _, subsection := range section.Sections {
+		linkSection(subsection)
+	}
+}
+
+// setParent sets the parent of contentFile to the page of the closest
+// section, starting with section, that has one, and adds contentFile to
+// the children of that page. It also sets the ancestors of contentFile
+// accordingly.
+func setParent(contentFile *ContentFile, section *Section) {
+	for ; section != nil; section = section.Parent {
+		if section.Page != nil {
+			contentFile.Parent = section.Page
+			section.Page.Children = append(section.Page.Children, contentFile)
+			break
+		}
+	}
+	contentFile.Ancestors = nil
+	for parent := contentFile.Parent; parent != nil; parent = parent.Parent {
+		contentFile.Ancestors = append([]*ContentFile{parent}, contentFile.Ancestors...)
+	}
+}
@@ -0,0 +1,108 @@
+package content
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestBuildSections(t *testing.T) {
+	newPage := func(sourcePath, title string) *ContentFile {
+		return &ContentFile{
+			Metadata:   ContentMetadata{Title: title},
+			Path:       "/" + sourcePath,
+			SourcePath: filepath.Join("input", sourcePath),
+		}
+	}
+	home := newPage("_index.md", "Home")
+	about := newPage("about.md", "About")
+	docs := newPage("docs/_index.md", "Docs")
+	install := newPage("docs/install.md", "Install")
+	advanced := newPage("docs/advanced/tuning.md", "Tuning")
+	trip := newPage("posts/my-trip/index.md", "My Trip")
+	tripNotes := newPage("posts/my-trip/notes/day1.md", "Day 1")
+	pages := []*ContentFile{home, about, docs, advanced, install, trip, tripNotes}
+
+	root, err := BuildSections("input", pages)
+	if err != nil {
+		t.Fatalf("unexpected error in BuildSections(): %s", err)
+	}
+
+	t.Run("should build section tree from directories", func(t *testing.T) {
+		if root.Page != home {
+			t.Errorf("root section page is not home page")
+		}
+		if len(root.Sections) != 2 {
+			t.Fatalf("got %d top-level sections but expected 2", len(root.Sections))
+		}
+		docsSection, postsSection := root.Sections[0], root.Sections[1]
+		if docsSection.Dir != "docs" || docsSection.Title != "Docs" || docsSection.Page != docs {
+			t.Errorf("unexpected docs section %+v", docsSection)
+		}
+		if postsSection.Dir != "posts" || postsSection.Title != "posts" || postsSection.Page != nil {
+			t.Errorf("unexpected posts section %+v", postsSection)
+		}
+		if len(docsSection.Sections) != 1 || docsSection.Sections[0].Dir != "docs/advanced" {
+			t.Errorf("unexpected subsections of docs section %v", docsSection.Sections)
+		}
+	})
+
+	t.Run("should put pages in page bundles in the section containing the bundle", func(t *testing.T) {
+		postsSection := root.Sections[1]
+		if trip.Section != postsSection || tripNotes.Section != postsSection {
+			t.Errorf("page bundle pages are not in posts section")
+		}
+	})
+
+	t.Run("should set parents, children and ancestors", func(t *testing.T) {
+		if install.Parent != docs || docs.Parent != home || home.Parent != nil {
+			t.Errorf("unexpected parents")
+		}
+		// the advanced section has no page, so its pages skip to docs
+		if advanced.Parent != docs {
+			t.Errorf("got parent %v for page in section without page", advanced.Parent)
+		}
+		// the posts section has no page, so its pages skip to home
+		if trip.Parent != home {
+			t.Errorf("got parent %v for page in page bundle", trip.Parent)
+		}
+		if len(install.Ancestors) != 2 || install.Ancestors[0] != home || install.Ancestors[1] != docs {
+			t.Errorf("unexpected ancestors %v", install.Ancestors)
+		}
+		expectedChildren := map[*ContentFile][]*ContentFile{
+			home: {about, docs, trip, tripNotes},
+			docs: {install, advanced},
+		}
+		for parent, expected := range expectedChildren {
+			if !slices.Equal(parent.Children, expected) {
+				t.Errorf("got children %v of %s but expected %v", parent.Children, parent.Path, expected)
+			}
+		}
+	})
+
+	t.Run("should make children the inverse of parent", func(t *testing.T) {
+		for _, page := range pages {
+			children := []*ContentFile{}
+			for _, child := range pages {
+				if child.Parent == page {
+					children = append(children, child)
+				}
+			}
+			if len(page.Children) != len(children) {
+				t.Errorf("got %d children of %s but %d pages have it as parent", len(page.Children), page.Path, len(children))
+			}
+			for _, child := range page.Children {
+				if child.Parent != page {
+					t.Errorf("%s is a child of %s but its parent is %v", child.Path, page.Path, child.Parent)
+				}
+			}
+		}
+	})
+
+	t.Run("should return error when section has two index pages", func(t *testing.T) {
+		pages := []*ContentFile{newPage("docs/_index.md", ""), newPage("docs/_index.md", "")}
+		if _, err := BuildSections("input", pages); err == nil {
+			t.Errorf("did not get error from BuildSections() when we should have")
+		}
+	})
+}
@@ -0,0 +1,210 @@
+package content
+
+import (
+	"strings"
+	"unicode"
+)
+
+// SummaryDivider may be placed in the markdown content of a content file to
+// mark the end of its summary.
+const SummaryDivider = "<!--more-->"
+
+// The reading speed used to compute reading time, in words per minute.
+const wordsPerMinute = 200
+
+// Elements that never have a closing tag.
+var voidElements = map[string]bool{
+	"area": true, "base": true, "br": true, "col": true, "embed": true,
+	"hr": true, "img": true, "input": true, "link": true, "meta": true,
+	"source": true, "track": true, "wbr": true,
+}
+
+// SplitSummary splits markdown content at the summary divider. It returns
+// the markdown before the divider, the content with the divider removed,
+// and whether the divider was found. The divider must be on a line of its
+// own outside of code blocks.
+func SplitSummary(rawContent string) (string, string, bool) {
+	// the fence that opened the code block the line is in, if any
+	fence := ""
+	offset := 0
+	for offset < len(rawContent) {
+		lineEnd := len(rawContent)
+		if newline := strings.IndexByte(rawContent[offset:], '\n'); newline != -1 {
+			lineEnd = offset + newline
+		}
+		line := rawContent[offset:lineEnd]
+		trimmed := strings.TrimSpace(line)
+		// Lines indented by four or more spaces are indented code.
+		indent := len(line) - len(strings.TrimLeft(line, " "))
+		indented := indent > 3 || strings.HasPrefix(line[indent:], "\t")
+		switch {
+		case indented:
+		case fence != "":
+			if strings.HasPrefix(trimmed, fence) && strings.Trim(trimmed, fence[:1]) == "" {
+				fence = ""
+			}
+		case strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~"):
+			fence = trimmed[:len(trimmed)-len(strings.TrimLeft(trimmed, trimmed[:1]))]
+		case trimmed == SummaryDivider:
+			before := rawContent[:offset]
+			return strings.TrimSpace(before), before + rawContent[lineEnd:], true
+		}
+		offset = lineEnd + 1
+	}
+	return "", rawContent, false
+}
+
+// CountWords returns the number of words in the text of an HTML document.
+func CountWords(html string) int {
+	count := 0
+	for _, token := range tokenizeHTML(html) {
+		if !token.isTag {
+			count += len(strings.Fields(token.value))
+		}
+	}
+	return count
+}
+
+// ReadingTime returns the estimated number of minutes it takes to read
+// wordCount words. It is always at least one minute.
+func ReadingTime(wordCount int) int {
+	minutes := (wordCount + wordsPerMinute - 1) / wordsPerMinute
+	return max(minutes, 1)
+}
+
+// TruncateHTML returns the first maxWords words of an HTML document. Tags
+// are never cut in half, and any elements that are open at the point of
+// truncation are closed. The returned bool indicates whether anything was
+// removed.
+func TruncateHTML(html string, maxWords int) (string, bool) {
+	builder := &strings.Builder{}
+	openElements := []openElement{}
+	words := 0
+
+	for _, token := range tokenizeHTML(html) {
+		if token.isTag {
+			builder.WriteString(token.value)
+			openElements = trackElement(openElements, token.value)
+			continue
+		}
+
+		remaining := token.value
+		for remaining != "" {
+			// copy leading whitespace
+			wordStart := strings.IndexFunc(remaining, isNotSpace)
+			if wordStart == -1 {
+				builder.WriteString(remaining)
+				break
+			}
+			if words == maxWords {
+				return closeElements(builder.String(), openElements), true
+			}
+			builder.WriteString(remaining[:wordStart])
+			remaining = remaining[wordStart:]
+
+			// copy word
+			wordEnd := strings.IndexFunc(remaining, unicode.IsSpace)
+			if wordEnd == -1 {
+				wordEnd = len(remaining)
+			}
+			builder.WriteString(remaining[:wordEnd])
+			remaining = remaining[wordEnd:]
+			words++
+		}
+	}
+
+	return html, false
+}
+
+func isNotSpace(r rune) bool {
+	return !unicode.IsSpace(r)
+}
+
+// openElement is an element whose opening tag has been seen, but whose
+// closing tag has not.
+type openElement struct {
+	name string
+	tag  string
+}
+
+// closeElements trims trailing whitespace from truncated and appends closing
+// tags for openElements. Elements that would be left empty are removed.
+func closeElements(truncated string, openElements []openElement) string {
+	truncated = strings.TrimRightFunc(truncated, unicode.IsSpace)
+	for len(openElements) > 0 {
+		last := openElements[len(openElements)-1]
+		if !strings.HasSuffix(truncated, last.tag) {
+			break
+		}
+		truncated = strings.TrimRightFunc(strings.TrimSuffix(truncated, last.tag), unicode.IsSpace)
+		openElements = openElements[:len(openElements)-1]
+	}
+
+	builder := &strings.Builder{}
+	builder.WriteString(truncated)
+	for i := len(openElements) - 1; i >= 0; i-- {
+		builder.WriteString("</" + openElements[i].name + ">")
+	}
+	return builder.String()
+}
+
+// trackElement updates the stack of open elements based on tag.
+func trackElement(openElements []openElement, tag string) []openElement {
+	if strings.HasPrefix(tag, "<!") || strings.HasPrefix(tag, "<?") || strings.HasSuffix(tag, "/>") {
+		return openElements
+	}
+	closing := strings.HasPrefix(tag, "</")
+	name := strings.TrimLeft(tag, "</")
+	if end := strings.IndexFunc(name, func(r rune) bool { return unicode.IsSpace(r) || r == '>' || r == '/' }); end != -1 {
+		name = name[:end]
+	}
+	name = strings.ToLower(name)
+	if voidElements[name] {
+		return openElements
+	}
+	if !closing {
+		return append(openElements, openElement{name: name, tag: tag})
+	}
+	for i := len(openElements) - 1; i >= 0; i-- {
+		if openElements[i].name == name {
+			return openElements[:i]
+		}
+	}
+	return openElements
+}
+
+type htmlToken struct {
+	value string
+	isTag bool
+}
+
+// tokenizeHTML splits an HTML document into tags (including comments) and
+// the text between them. It is not a full HTML parser, but it handles the
+// HTML that goldmark produces.
+func tokenizeHTML(html string) []htmlToken {
+	tokens := []htmlToken{}
+	for html != "" {
+		start := strings.IndexByte(html, '<')
+		if start == -1 {
+			tokens = append(tokens, htmlToken{value: html})
+			break
+		}
+		if start > 0 {
+			tokens = append(tokens, htmlToken{value: html[:start]})
+			html = html[start:]
+		}
+		end := strings.IndexByte(html, '>')
+		if strings.HasPrefix(html, "<!--") {
+			if commentEnd := strings.Index(html, "-->"); commentEnd != -1 {
+				end = commentEnd + len("-->") - 1
+			}
+		}
+		if end == -1 {
+			tokens = append(tokens, htmlToken{value: html})
+			break
+		}
+		tokens = append(tokens, htmlToken{value: html[:end+1], isTag: true})
+		html = html[end+1:]
+	}
+	return tokens
+}
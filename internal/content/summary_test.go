@@ -0,0 +1,129 @@
+package content
+
+import "testing"
+
+func TestSplitSummary(t *testing.T) {
+	t.Run("should split at divider", func(t *testing.T) {
+		rawSummary, rawContent, found := SplitSummary("First paragraph.\n\n<!--more-->\n\nSecond paragraph.")
+		if !found {
+			t.Fatalf("did not find divider")
+		}
+		if expected := "First paragraph."; rawSummary != expected {
+			t.Errorf("got summary %q but expected %q", rawSummary, expected)
+		}
+		if expected := "First paragraph.\n\n\n\nSecond paragraph."; rawContent != expected {
+			t.Errorf("got content %q but expected %q", rawContent, expected)
+		}
+	})
+
+	for _, rawContent := range []string{
+		"Use `<!--more-->` to end the summary.",
+		"Example:\n\n```markdown\nSummary.\n\n<!--more-->\n```\n\nThe end.",
+		"Example:\n\n~~~~\n<!--more-->\n~~~\n~~~~\n\nThe end.",
+		"Example:\n\n    <!--more-->\n",
+	} {
+		t.Run("should not split at divider in code: "+rawContent, func(t *testing.T) {
+			_, got, found := SplitSummary(rawContent)
+			if found {
+				t.Errorf("found divider when there is none")
+			}
+			if got != rawContent {
+				t.Errorf("got content %q but expected %q", got, rawContent)
+			}
+		})
+	}
+
+	t.Run("should split at divider after code block", func(t *testing.T) {
+		rawSummary, _, found := SplitSummary("```\n<!--more-->\n```\n<!--more-->\nRest.")
+		if !found {
+			t.Fatalf("did not find divider")
+		}
+		if expected := "```\n<!--more-->\n```"; rawSummary != expected {
+			t.Errorf("got summary %q but expected %q", rawSummary, expected)
+		}
+	})
+
+	t.Run("should return content unchanged when there is no divider", func(t *testing.T) {
+		_, rawContent, found := SplitSummary("No divider here.")
+		if found {
+			t.Errorf("found divider when there is none")
+		}
+		if expected := "No divider here."; rawContent != expected {
+			t.Errorf("got content %q but expected %q", rawContent, expected)
+		}
+	})
+}
+
+func TestTruncateHTML(t *testing.T) {
+	testCases := []struct {
+		Name              string
+		HTML              string
+		MaxWords          int
+		ExpectedHTML      string
+		ExpectedTruncated bool
+	}{
+		{
+			Name:              "should not truncate short content",
+			HTML:              "<p>one two three</p>\n",
+			MaxWords:          5,
+			ExpectedHTML:      "<p>one two three</p>\n",
+			ExpectedTruncated: false,
+		},
+		{
+			Name:              "should not truncate content with exactly the max number of words",
+			HTML:              "<p>one two three</p>\n",
+			MaxWords:          3,
+			ExpectedHTML:      "<p>one two three</p>\n",
+			ExpectedTruncated: false,
+		},
+		{
+			Name:              "should close open elements",
+			HTML:              "<p>one <em>two three</em> four</p>\n<p>five</p>\n",
+			MaxWords:          2,
+			ExpectedHTML:      "<p>one <em>two</em></p>",
+			ExpectedTruncated: true,
+		},
+		{
+			Name:              "should truncate across paragraphs",
+			HTML:              "<p>one two</p>\n<p>three <a href=\"/a b\">four</a></p>\n",
+			MaxWords:          3,
+			ExpectedHTML:      "<p>one two</p>\n<p>three</p>",
+			ExpectedTruncated: true,
+		},
+		{
+			Name:              "should ignore void elements and comments",
+			HTML:              "<p>one<br>\n<!-- a comment -->two <img src=\"/a.png\" alt=\"x y\"> three</p>\n",
+			MaxWords:          2,
+			ExpectedHTML:      "<p>one<br>\n<!-- a comment -->two <img src=\"/a.png\" alt=\"x y\"></p>",
+			ExpectedTruncated: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			html, truncated := TruncateHTML(testCase.HTML, testCase.MaxWords)
+			if html != testCase.ExpectedHTML {
+				t.Errorf("got %q but expected %q", html, testCase.ExpectedHTML)
+			}
+			if truncated != testCase.ExpectedTruncated {
+				t.Errorf("got truncated %t but expected %t", truncated, testCase.ExpectedTruncated)
+			}
+		})
+	}
+}
+
+func TestCountWords(t *testing.T) {
+	html := "<h1>A title</h1>\n<p>Some <em>emphasized</em> text.</p>\n"
+	if count := CountWords(html); count != 5 {
+		t.Errorf("got %d words but expected 5", count)
+	}
+}
+
+func TestReadingTime(t *testing.T) {
+	testCases := map[int]int{0: 1, 1: 1, 200: 1, 201: 2, 1000: 5}
+	for wordCount, expected := range testCases {
+		if minutes := ReadingTime(wordCount); minutes != expected {
+			t.Errorf("got %d minutes for %d words but expected %d", minutes, wordCount, expected)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package data
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/adamkpickering/jenny/internal/ignore"
+	"gopkg.in/yaml.v3"
+)
+
+// Load reads every YAML, JSON, TOML and CSV file in dataDir into a nested
+// map. Each directory becomes a map, and each file becomes a key in the map
+// of its directory whose name is the name of the file without its
+// extension. Other files are ignored, as are the files and directories
+// that ignored ignores. If dataDir does not exist, Load returns an empty
+// map.
+func Load(dataDir string, ignored *ignore.Matcher) (map[string]any, error) {
+	data := map[string]any{}
+	if _, err := os.Stat(dataDir); errors.Is(err, fs.ErrNotExist) {
+		return data, nil
+	}
+
+	walkDirFunc := func(filePath string, dirEntry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if filePath != dataDir && ignored.Ignored(filePath, dirEntry.IsDir()) {
+			if dirEntry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if dirEntry.IsDir() {
+			return nil
+		}
+
+		ext := filepath.Ext(filePath)
+		decode, ok := decoders[ext]
+		if !ok {
+			return nil
+		}
+		contents, err := os.ReadFile(filePath)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", filePath, err)
+		}
+		value, err := decode(contents)
+		if err != nil {
+			return fmt.Errorf("failed to parse %s: %w", filePath, err)
+		}
+
+		relativePath, err := filepath.Rel(dataDir, filePath)
+		if err != nil {
+			return fmt.Errorf("failed to get path of %s relative to %s: %w", filePath, dataDir, err)
+		}
+		keys := strings.Split(filepath.ToSlash(strings.TrimSuffix(relativePath, ext)), "/")
+		if err := insert(data, keys, value); err != nil {
+			return fmt.Errorf("failed to add %s: %w", filePath, err)
+		}
+
+		return nil
+	}
+
+	if err := filepath.WalkDir(dataDir, walkDirFunc); err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// insert sets the value at the path given by keys in data, creating maps
+// for directories as needed.
+func insert(data map[string]any, keys []string, value any) error {
+	current := data
+	for i, key := range keys[:len(keys)-1] {
+		existing, ok := current[key]
+		if !ok {
+			next := map[string]any{}
+			current[key] = next
+			current = next
+			continue
+		}
+		next, ok := existing.(map[string]any)
+		if !ok {
+			return fmt.Errorf("%s is both a file and a directory", strings.Join(keys[:i+1], "/"))
+		}
+		current = next
+	}
+
+	key := keys[len(keys)-1]
+	if _, ok := current[key]; ok {
+		return fmt.Errorf("more than one file or directory is called %s", strings.Join(keys, "/"))
+	}
+	current[key] = value
+	return nil
+}
+
+var decoders = map[string]func([]byte) (any, error){
+	".yaml": decodeYaml,
+	".yml":  decodeYaml,
+	".json": decodeJson,
+	".toml": decodeToml,
+	".csv":  decodeCsv,
+}
+
+func decodeYaml(contents []byte) (any, error) {
+	var value any
+	if err := yaml.Unmarshal(contents, &value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+func decodeJson(contents []byte) (any, error) {
+	var value any
+	if err := json.Unmarshal(contents, &value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+func decodeToml(contents []byte) (any, error) {
+	value := map[string]any{}
+	if err := toml.Unmarshal(contents, &value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// decodeCsv returns the rows of a CSV file, each of which is a slice of
+// fields.
+func decodeCsv(contents []byte) (any, error) {
+	reader := csv.NewReader(bytes.NewReader(contents))
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	return records, nil
+}
@@ -0,0 +1,107 @@
+package data
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/adamkpickering/jenny/internal/ignore"
+)
+
+func writeFiles(t *testing.T, dataDir string, files map[string]string) {
+	t.Helper()
+	for name, contents := range files {
+		filePath := filepath.Join(dataDir, name)
+		if err := os.MkdirAll(filepath.Dir(filePath), 0o755); err != nil {
+			t.Fatalf("failed to create parent dir of %s: %s", name, err)
+		}
+		if err := os.WriteFile(filePath, []byte(contents), 0o644); err != nil {
+			t.Fatalf("failed to write %s: %s", name, err)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	t.Run("should load all supported formats into nested map", func(t *testing.T) {
+		dataDir := t.TempDir()
+		writeFiles(t, dataDir, map[string]string{
+			"links.yaml":          "- name: Go\n  url: https://go.dev\n",
+			"team/roster.json":    `{"members": ["alice", "bob"]}`,
+			"team/projects.toml":  "[jenny]\nlanguage = \"go\"\n",
+			"team/old/list.csv":   "name,role\nalice,lead\n",
+			"team/notes.txt":      "ignored",
+			"team/old/config.yml": "archived: true\n",
+		})
+		data, err := Load(dataDir, nil)
+		if err != nil {
+			t.Fatalf("unexpected error in Load(): %s", err)
+		}
+		expected := map[string]any{
+			"links": []any{
+				map[string]any{"name": "Go", "url": "https://go.dev"},
+			},
+			"team": map[string]any{
+				"roster":   map[string]any{"members": []any{"alice", "bob"}},
+				"projects": map[string]any{"jenny": map[string]any{"language": "go"}},
+				"old": map[string]any{
+					"list":   [][]string{{"name", "role"}, {"alice", "lead"}},
+					"config": map[string]any{"archived": true},
+				},
+			},
+		}
+		if !reflect.DeepEqual(data, expected) {
+			t.Errorf("got %#v but expected %#v", data, expected)
+		}
+	})
+
+	t.Run("should return empty map when data dir does not exist", func(t *testing.T) {
+		data, err := Load(filepath.Join(t.TempDir(), "does-not-exist"), nil)
+		if err != nil {
+			t.Fatalf("unexpected error in Load(): %s", err)
+		}
+		if len(data) != 0 {
+			t.Errorf("got %v but expected empty map", data)
+		}
+	})
+
+	t.Run("should skip ignored files and directories", func(t *testing.T) {
+		dataDir := t.TempDir()
+		writeFiles(t, dataDir, map[string]string{
+			"links.yaml":       "a: b\n",
+			"links.draft.yaml": "{",
+			"old/list.json":    "{",
+		})
+		ignored, err := ignore.New(dataDir, []string{"*.draft.yaml", "old/"})
+		if err != nil {
+			t.Fatalf("unexpected error in ignore.New(): %s", err)
+		}
+		data, err := Load(dataDir, ignored)
+		if err != nil {
+			t.Fatalf("unexpected error in Load(): %s", err)
+		}
+		expected := map[string]any{"links": map[string]any{"a": "b"}}
+		if !reflect.DeepEqual(data, expected) {
+			t.Errorf("got %#v but expected %#v", data, expected)
+		}
+	})
+
+	t.Run("should return error when file and directory have the same name", func(t *testing.T) {
+		dataDir := t.TempDir()
+		writeFiles(t, dataDir, map[string]string{
+			"team.yaml":        "a: b\n",
+			"team/roster.json": "{}",
+		})
+		if _, err := Load(dataDir, nil); err == nil {
+			t.Errorf("did not get error from Load() when we should have")
+		}
+	})
+
+	t.Run("should return error when file cannot be parsed", func(t *testing.T) {
+		dataDir := t.TempDir()
+		writeFiles(t, dataDir, map[string]string{"bad.json": "{"})
+		if _, err := Load(dataDir, nil); err == nil {
+			t.Errorf("did not get error from Load() when we should have")
+		}
+	})
+}
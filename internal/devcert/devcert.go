@@ -0,0 +1,215 @@
+package devcert
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	// CACertFile is the name of the file that contains the certificate of
+	// the CA. It is the file that must be trusted by browsers.
+	CACertFile = "ca.pem"
+	caKeyFile  = "ca-key.pem"
+	certFile   = "cert.pem"
+	keyFile    = "key.pem"
+)
+
+const (
+	caValidity = 10 * 365 * 24 * time.Hour
+	// Some platforms do not trust server certificates that are valid for
+	// longer than 825 days.
+	certValidity = 825 * 24 * time.Hour
+	// Certificates that expire sooner than this are replaced.
+	renewBefore = 7 * 24 * time.Hour
+)
+
+// Load returns a certificate for hosts, which may be host names or IP
+// addresses, that is signed by the local CA in dir. The CA is created if
+// it does not exist yet. The certificate is stored in dir too, and is
+// replaced if it does not cover all of hosts or is about to expire.
+func Load(dir string, hosts []string) (tls.Certificate, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return tls.Certificate{}, fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+
+	caCert, caKey, err := loadOrCreateCA(dir)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	certPath := filepath.Join(dir, certFile)
+	keyPath := filepath.Join(dir, keyFile)
+	cert, err := tls.LoadX509KeyPair(certPath, keyPath)
+	if err == nil && isUsable(cert, caCert, hosts) {
+		return cert, nil
+	}
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return tls.Certificate{}, fmt.Errorf("failed to load certificate: %w", err)
+	}
+
+	certPEM, keyPEM, err := createCert(caCert, caKey, hosts)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("failed to create certificate: %w", err)
+	}
+	if err := writePEMFiles(certPath, certPEM, keyPath, keyPEM); err != nil {
+		return tls.Certificate{}, err
+	}
+	return tls.X509KeyPair(certPEM, keyPEM)
+}
+
+// loadOrCreateCA returns the CA in dir, creating it if it does not exist.
+func loadOrCreateCA(dir string) (*x509.Certificate, *ecdsa.PrivateKey, error) {
+	caCertPath := filepath.Join(dir, CACertFile)
+	caKeyPath := filepath.Join(dir, caKeyFile)
+
+	caPair, err := tls.LoadX509KeyPair(caCertPath, caKeyPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		caCertPEM, caKeyPEM, err := createCA()
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to create CA: %w", err)
+		}
+		if err := writePEMFiles(caCertPath, caCertPEM, caKeyPath, caKeyPEM); err != nil {
+			return nil, nil, err
+		}
+		caPair, err = tls.X509KeyPair(caCertPEM, caKeyPEM)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to parse CA: %w", err)
+		}
+	} else if err != nil {
+		return nil, nil, fmt.Errorf("failed to load CA: %w", err)
+	}
+
+	caCert, err := leafOf(caPair)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse CA: %w", err)
+	}
+	caKey, ok := caPair.PrivateKey.(*ecdsa.PrivateKey)
+	if !ok {
+		return nil, nil, fmt.Errorf("key in %s is not an ECDSA key", caKeyPath)
+	}
+	return caCert, caKey, nil
+}
+
+// leafOf returns the parsed form of the first certificate in cert.
+func leafOf(cert tls.Certificate) (*x509.Certificate, error) {
+	if cert.Leaf != nil {
+		return cert.Leaf, nil
+	}
+	if len(cert.Certificate) == 0 {
+		return nil, errors.New("no certificate found")
+	}
+	return x509.ParseCertificate(cert.Certificate[0])
+}
+
+// isUsable returns whether cert was signed by caCert, covers all of hosts
+// and is not about to expire.
+func isUsable(cert tls.Certificate, caCert *x509.Certificate, hosts []string) bool {
+	leaf, err := leafOf(cert)
+	if err != nil || !bytes.Equal(leaf.RawIssuer, caCert.RawSubject) {
+		return false
+	}
+	if leaf.CheckSignatureFrom(caCert) != nil {
+		return false
+	}
+	if time.Now().Add(renewBefore).After(leaf.NotAfter) {
+		return false
+	}
+	for _, host := range hosts {
+		if leaf.VerifyHostname(host) != nil {
+			return false
+		}
+	}
+	return true
+}
+
+func createCA() ([]byte, []byte, error) {
+	now := time.Now()
+	template := &x509.Certificate{
+		Subject: pkix.Name{
+			Organization: []string{"jenny"},
+			CommonName:   "jenny development CA",
+		},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(caValidity),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLenZero:        true,
+	}
+	return createCertificate(template, nil, nil)
+}
+
+func createCert(caCert *x509.Certificate, caKey *ecdsa.PrivateKey, hosts []string) ([]byte, []byte, error) {
+	now := time.Now()
+	template := &x509.Certificate{
+		Subject: pkix.Name{
+			Organization: []string{"jenny"},
+			CommonName:   "jenny development server",
+		},
+		NotBefore:   now.Add(-time.Hour),
+		NotAfter:    now.Add(certValidity),
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	for _, host := range hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, host)
+		}
+	}
+	return createCertificate(template, caCert, caKey)
+}
+
+// createCertificate generates a key and creates a certificate for it from
+// template, signed by parent. If parent is nil, the certificate is
+// self-signed. The certificate and key are returned PEM-encoded.
+func createCertificate(template, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) ([]byte, []byte, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to generate key: %w", err)
+	}
+	template.SerialNumber, err = rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to generate serial number: %w", err)
+	}
+	if parent == nil {
+		parent = template
+		parentKey = key
+	}
+	certDER, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create certificate: %w", err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to marshal key: %w", err)
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})
+	return certPEM, keyPEM, nil
+}
+
+func writePEMFiles(certPath string, certPEM []byte, keyPath string, keyPEM []byte) error {
+	if err := os.WriteFile(keyPath, keyPEM, 0o600); err != nil {
+		return fmt.Errorf("failed to write %s: %w", keyPath, err)
+	}
+	if err := os.WriteFile(certPath, certPEM, 0o644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", certPath, err)
+	}
+	return nil
+}
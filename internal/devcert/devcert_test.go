@@ -0,0 +1,107 @@
+package devcert
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func verify(t *testing.T, dir, host string) {
+	t.Helper()
+	caPEM, err := os.ReadFile(filepath.Join(dir, CACertFile))
+	if err != nil {
+		t.Fatalf("failed to read CA: %s", err)
+	}
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(caPEM) {
+		t.Fatalf("failed to parse CA")
+	}
+	certPEM, err := os.ReadFile(filepath.Join(dir, certFile))
+	if err != nil {
+		t.Fatalf("failed to read certificate: %s", err)
+	}
+	block, _ := pem.Decode(certPEM)
+	if block == nil {
+		t.Fatalf("failed to decode certificate")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatalf("failed to parse certificate: %s", err)
+	}
+	opts := x509.VerifyOptions{
+		DNSName:   host,
+		Roots:     roots,
+		KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	if _, err := cert.Verify(opts); err != nil {
+		t.Errorf("failed to verify certificate for %s: %s", host, err)
+	}
+}
+
+func readFile(t *testing.T, filePath string) []byte {
+	t.Helper()
+	contents, err := os.ReadFile(filePath)
+	if err != nil {
+		t.Fatalf("failed to read %s: %s", filePath, err)
+	}
+	return contents
+}
+
+func TestLoad(t *testing.T) {
+	t.Run("should create CA and certificate for hosts", func(t *testing.T) {
+		dir := filepath.Join(t.TempDir(), "tls")
+		if _, err := Load(dir, []string{"localhost", "192.168.1.20"}); err != nil {
+			t.Fatalf("unexpected error in Load(): %s", err)
+		}
+		verify(t, dir, "localhost")
+		verify(t, dir, "192.168.1.20")
+		fileInfo, err := os.Stat(filepath.Join(dir, caKeyFile))
+		if err != nil {
+			t.Fatalf("failed to stat CA key: %s", err)
+		}
+		if mode := fileInfo.Mode().Perm(); mode != 0o600 {
+			t.Errorf("got CA key mode %o but expected %o", mode, 0o600)
+		}
+	})
+
+	t.Run("should reuse CA and certificate", func(t *testing.T) {
+		dir := t.TempDir()
+		hosts := []string{"localhost", "::1"}
+		if _, err := Load(dir, hosts); err != nil {
+			t.Fatalf("unexpected error in Load(): %s", err)
+		}
+		caPEM := readFile(t, filepath.Join(dir, CACertFile))
+		certPEM := readFile(t, filepath.Join(dir, certFile))
+		if _, err := Load(dir, hosts); err != nil {
+			t.Fatalf("unexpected error in Load(): %s", err)
+		}
+		if !bytes.Equal(readFile(t, filepath.Join(dir, CACertFile)), caPEM) {
+			t.Errorf("CA was replaced but should have been reused")
+		}
+		if !bytes.Equal(readFile(t, filepath.Join(dir, certFile)), certPEM) {
+			t.Errorf("certificate was replaced but should have been reused")
+		}
+	})
+
+	t.Run("should replace certificate but not CA when hosts change", func(t *testing.T) {
+		dir := t.TempDir()
+		if _, err := Load(dir, []string{"localhost"}); err != nil {
+			t.Fatalf("unexpected error in Load(): %s", err)
+		}
+		caPEM := readFile(t, filepath.Join(dir, CACertFile))
+		certPEM := readFile(t, filepath.Join(dir, certFile))
+		if _, err := Load(dir, []string{"localhost", "10.0.0.5"}); err != nil {
+			t.Fatalf("unexpected error in Load(): %s", err)
+		}
+		if !bytes.Equal(readFile(t, filepath.Join(dir, CACertFile)), caPEM) {
+			t.Errorf("CA was replaced but should have been reused")
+		}
+		if bytes.Equal(readFile(t, filepath.Join(dir, certFile)), certPEM) {
+			t.Errorf("certificate was reused but should have been replaced")
+		}
+		verify(t, dir, "10.0.0.5")
+	})
+}
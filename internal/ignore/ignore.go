@@ -0,0 +1,203 @@
+package ignore
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// FileName is the name of the file, in the directory of the configuration
+// file, that lists patterns of files to ignore.
+const FileName = ".jennyignore"
+
+// pattern is a parsed gitignore pattern.
+type pattern struct {
+	// the segments of the pattern, where ** matches any number of
+	// segments
+	segments []string
+	// whether the pattern re-includes files that an earlier pattern
+	// ignored
+	negated bool
+	// whether the pattern only matches directories
+	dirOnly bool
+}
+
+// Matcher decides which files to ignore, using patterns with the
+// semantics of .gitignore files. Patterns are matched against paths
+// relative to a root directory.
+type Matcher struct {
+	root     string
+	patterns []pattern
+}
+
+// New returns a Matcher that matches patterns against paths relative to
+// root. Each pattern is a line of a .gitignore file.
+func New(root string, patterns []string) (*Matcher, error) {
+	matcher := &Matcher{root: root}
+	for _, line := range patterns {
+		if err := matcher.add(line); err != nil {
+			return nil, err
+		}
+	}
+	return matcher, nil
+}
+
+// Read returns a Matcher for patterns, followed by the patterns in the
+// .jennyignore file in root, if there is one. Since later patterns take
+// precedence, the file can re-include files that patterns ignore.
+func Read(root string, patterns []string) (*Matcher, error) {
+	matcher, err := New(root, patterns)
+	if err != nil {
+		return nil, err
+	}
+	ignorePath := filepath.Join(root, FileName)
+	contents, err := os.ReadFile(ignorePath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return matcher, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", ignorePath, err)
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(contents))
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		if err := matcher.add(scanner.Text()); err != nil {
+			return nil, fmt.Errorf("%s line %d: %w", ignorePath, lineNumber, err)
+		}
+	}
+	return matcher, nil
+}
+
+// add parses a line of a .gitignore file and adds the pattern in it, if
+// any.
+func (matcher *Matcher) add(line string) error {
+	line = strings.TrimSuffix(line, "\r")
+	original := line
+	// Trailing spaces are ignored unless they are escaped.
+	for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, `\ `) {
+		line = strings.TrimSuffix(line, " ")
+	}
+	if line == "" || strings.HasPrefix(line, "#") {
+		return nil
+	}
+
+	p := pattern{}
+	if strings.HasPrefix(line, "!") {
+		p.negated = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, `\!`) || strings.HasPrefix(line, `\#`) {
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		p.dirOnly = true
+		line = strings.TrimSuffix(line, "/")
+	}
+	// A pattern with a slash other than at the end is relative to the
+	// root. Any other pattern matches at any level.
+	if !strings.Contains(line, "/") {
+		line = "**/" + line
+	}
+	line = strings.TrimPrefix(line, "/")
+	if line == "" {
+		return fmt.Errorf("invalid pattern %q", original)
+	}
+	p.segments = strings.Split(line, "/")
+	for _, segment := range p.segments {
+		if _, err := path.Match(segment, ""); err != nil {
+			return fmt.Errorf("invalid pattern %q: %w", original, err)
+		}
+	}
+	matcher.patterns = append(matcher.patterns, p)
+	return nil
+}
+
+// Ignored returns whether the file or directory at filePath is ignored.
+// A file is ignored if a pattern matches it, or if it is in a directory
+// that is ignored. A nil Matcher ignores nothing.
+func (matcher *Matcher) Ignored(filePath string, isDir bool) bool {
+	if matcher == nil {
+		return false
+	}
+	relativePath := matcher.relativePath(filePath)
+	if relativePath == "" {
+		return false
+	}
+	segments := strings.Split(relativePath, "/")
+	// As with git, files in an ignored directory cannot be re-included.
+	for i := 1; i < len(segments); i++ {
+		if matcher.matches(segments[:i], true) {
+			return true
+		}
+	}
+	return matcher.matches(segments, isDir)
+}
+
+// relativePath returns filePath relative to the root, using forward
+// slashes, or "" if it is the root. Paths outside the root are returned as
+// absolute paths without the leading slash, so that patterns that match
+// at any level can still match them.
+func (matcher *Matcher) relativePath(filePath string) string {
+	absRoot, err := filepath.Abs(matcher.root)
+	if err != nil {
+		return filepath.ToSlash(filePath)
+	}
+	absPath, err := filepath.Abs(filePath)
+	if err != nil {
+		return filepath.ToSlash(filePath)
+	}
+	relativePath, err := filepath.Rel(absRoot, absPath)
+	if err == nil && relativePath == "." {
+		return ""
+	}
+	if err == nil && filepath.IsLocal(relativePath) {
+		return filepath.ToSlash(relativePath)
+	}
+	return strings.TrimPrefix(filepath.ToSlash(absPath), "/")
+}
+
+// matches returns whether the last pattern that matches segments ignores
+// them rather than re-including them.
+func (matcher *Matcher) matches(segments []string, isDir bool) bool {
+	ignored := false
+	for _, p := range matcher.patterns {
+		if p.dirOnly && !isDir {
+			continue
+		}
+		if matchSegments(p.segments, segments) {
+			ignored = !p.negated
+		}
+	}
+	return ignored
+}
+
+// matchSegments returns whether the segments of a pattern match the
+// segments of a path.
+func matchSegments(patternSegments, pathSegments []string) bool {
+	if len(patternSegments) == 0 {
+		return len(pathSegments) == 0
+	}
+	if patternSegments[0] == "**" {
+		// A trailing ** matches everything inside, but not the
+		// directory itself.
+		if len(patternSegments) == 1 {
+			return len(pathSegments) > 0
+		}
+		for i := 0; i <= len(pathSegments); i++ {
+			if matchSegments(patternSegments[1:], pathSegments[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(pathSegments) == 0 {
+		return false
+	}
+	if ok, _ := path.Match(patternSegments[0], pathSegments[0]); !ok {
+		return false
+	}
+	return matchSegments(patternSegments[1:], pathSegments[1:])
+}
@@ -0,0 +1,129 @@
+package ignore
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestIgnored(t *testing.T) {
+	patterns := []string{
+		"# comment",
+		"",
+		".DS_Store",
+		"*.psd",
+		"/input/README.md",
+		"drafts/",
+		"input/static/**/*.map",
+		"vendor/**",
+		"*.log",
+		"!keep.log",
+		`\#notes`,
+	}
+	matcher, err := New("site", patterns)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	cases := []struct {
+		filePath string
+		isDir    bool
+		expected bool
+	}{
+		{"site/input/.DS_Store", false, true},
+		{"site/input/photos/.DS_Store", false, true},
+		{"site/input/photos/cover.psd", false, true},
+		{"site/input/photos/cover.jpg", false, false},
+		{"site/input/README.md", false, true},
+		{"site/input/posts/README.md", false, false},
+		{"site/input/drafts", true, true},
+		{"site/input/drafts/post.md", false, true},
+		{"site/input/drafts", false, false},
+		{"site/input/static/js/app.js.map", false, true},
+		{"site/input/static/app.js.map", false, true},
+		{"site/input/app.js.map", false, false},
+		{"site/input/vendor", true, false},
+		{"site/input/vendor/a.js", false, false},
+		{"site/vendor/a.js", false, true},
+		{"site/input/debug.log", false, true},
+		{"site/input/keep.log", false, false},
+		{"site/input/#notes", false, true},
+		{"site/input/.post.md.swp", false, false},
+		{"site", true, false},
+		{"/elsewhere/input/cover.psd", false, true},
+	}
+	for _, c := range cases {
+		t.Run(c.filePath, func(t *testing.T) {
+			if got := matcher.Ignored(c.filePath, c.isDir); got != c.expected {
+				t.Errorf("got %t but expected %t", got, c.expected)
+			}
+		})
+	}
+
+	t.Run("should not re-include files in ignored directories", func(t *testing.T) {
+		matcher, err := New(".", []string{"drafts/", "!drafts/keep.md"})
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if !matcher.Ignored("drafts/keep.md", false) {
+			t.Errorf("got false but expected true")
+		}
+	})
+
+	t.Run("should ignore nothing when nil", func(t *testing.T) {
+		var matcher *Matcher
+		if matcher.Ignored("input/post.md", false) || matcher.Ignored("input/post.md~", false) {
+			t.Errorf("got true but expected false")
+		}
+	})
+
+	t.Run("should return error for invalid pattern", func(t *testing.T) {
+		if _, err := New(".", []string{"static/["}); err == nil {
+			t.Errorf("did not get error when we should have")
+		}
+	})
+}
+
+func TestRead(t *testing.T) {
+	t.Run("should add patterns from the ignore file after the others", func(t *testing.T) {
+		root := t.TempDir()
+		if err := os.WriteFile(filepath.Join(root, FileName), []byte("*.md\n!index.md\n"), 0o644); err != nil {
+			t.Fatalf("failed to write ignore file: %s", err)
+		}
+		matcher, err := Read(root, []string{"index.md", "*.psd"})
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		cases := map[string]bool{"post.md": true, "index.md": false, "a.psd": true, "a.jpg": false}
+		for name, expected := range cases {
+			if got := matcher.Ignored(filepath.Join(root, "input", name), false); got != expected {
+				t.Errorf("got %t for %s but expected %t", got, name, expected)
+			}
+		}
+	})
+
+	t.Run("should work without an ignore file", func(t *testing.T) {
+		matcher, err := Read(t.TempDir(), nil)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if matcher.Ignored("index.md", false) {
+			t.Errorf("got true but expected false")
+		}
+	})
+
+	t.Run("should return error with line number for invalid pattern", func(t *testing.T) {
+		root := t.TempDir()
+		if err := os.WriteFile(filepath.Join(root, FileName), []byte("*.md\n[\n"), 0o644); err != nil {
+			t.Fatalf("failed to write ignore file: %s", err)
+		}
+		_, err := Read(root, nil)
+		if err == nil {
+			t.Fatalf("did not get error when we should have")
+		}
+		if expected := FileName + " line 2"; !strings.Contains(err.Error(), expected) {
+			t.Errorf("got error %q but expected it to contain %q", err, expected)
+		}
+	})
+}
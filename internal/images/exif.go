@@ -0,0 +1,114 @@
+package images
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+const (
+	markerStartOfImage = 0xd8
+	markerStartOfScan  = 0xda
+	markerEndOfImage   = 0xd9
+	markerAPP1         = 0xe1
+	orientationTag     = 0x0112
+)
+
+// The header of the APP1 segment that holds EXIF metadata. It is followed
+// by a TIFF header and the image file directories.
+var exifHeader = []byte("Exif\x00\x00")
+
+// exifSegment returns the APP1 segment of the JPEG in contents that holds
+// its EXIF metadata, including the marker and the length, or nil if there
+// is none.
+func exifSegment(contents []byte) []byte {
+	if len(contents) < 4 || contents[0] != 0xff || contents[1] != markerStartOfImage {
+		return nil
+	}
+	offset := 2
+	for offset+4 <= len(contents) {
+		if contents[offset] != 0xff {
+			return nil
+		}
+		marker := contents[offset+1]
+		if marker == markerStartOfScan || marker == markerEndOfImage {
+			return nil
+		}
+		length := int(binary.BigEndian.Uint16(contents[offset+2:]))
+		end := offset + 2 + length
+		if length < 2 || end > len(contents) {
+			return nil
+		}
+		if marker == markerAPP1 && bytes.HasPrefix(contents[offset+4:end], exifHeader) {
+			return contents[offset:end]
+		}
+		offset = end
+	}
+	return nil
+}
+
+// orientationOffset returns the offset in segment of the value of the
+// orientation tag, along with the byte order of the value, or -1 if
+// segment has no orientation tag.
+func orientationOffset(segment []byte) (int, binary.ByteOrder) {
+	// the offset of the TIFF header, after the marker, length and EXIF
+	// header
+	tiffHeader := 4 + len(exifHeader)
+	if len(segment) < tiffHeader+8 {
+		return -1, nil
+	}
+	var byteOrder binary.ByteOrder
+	switch string(segment[tiffHeader : tiffHeader+2]) {
+	case "II":
+		byteOrder = binary.LittleEndian
+	case "MM":
+		byteOrder = binary.BigEndian
+	default:
+		return -1, nil
+	}
+	directory := tiffHeader + int(byteOrder.Uint32(segment[tiffHeader+4:]))
+	if directory < tiffHeader+8 || directory+2 > len(segment) {
+		return -1, nil
+	}
+	entries := int(byteOrder.Uint16(segment[directory:]))
+	for i := range entries {
+		entry := directory + 2 + i*12
+		if entry+12 > len(segment) {
+			return -1, nil
+		}
+		if byteOrder.Uint16(segment[entry:]) == orientationTag {
+			return entry + 8, byteOrder
+		}
+	}
+	return -1, nil
+}
+
+// orientation returns the EXIF orientation of the JPEG in contents, from
+// 1 to 8. Images without one have an orientation of 1, which needs no
+// transformation.
+func orientation(contents []byte) int {
+	segment := exifSegment(contents)
+	offset, byteOrder := orientationOffset(segment)
+	if offset < 0 {
+		return 1
+	}
+	value := int(byteOrder.Uint16(segment[offset:]))
+	if value < 1 || value > 8 {
+		return 1
+	}
+	return value
+}
+
+// withExif returns the JPEG in contents with the EXIF segment inserted
+// after its start of image marker. The orientation in the segment is reset
+// to 1, since images are rotated as their orientation says when they are
+// processed.
+func withExif(contents, segment []byte) []byte {
+	segment = bytes.Clone(segment)
+	if offset, byteOrder := orientationOffset(segment); offset >= 0 {
+		byteOrder.PutUint16(segment[offset:], 1)
+	}
+	withSegment := make([]byte, 0, len(contents)+len(segment))
+	withSegment = append(withSegment, contents[:2]...)
+	withSegment = append(withSegment, segment...)
+	return append(withSegment, contents[2:]...)
+}
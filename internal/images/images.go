@@ -0,0 +1,397 @@
+package images
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/adamkpickering/jenny/internal/asset"
+	"github.com/disintegration/imaging"
+)
+
+// The number of bytes of the hash of a processed image that are added to
+// its name.
+const hashLength = 8
+
+// The formats of the images that can be processed, by extension.
+var formats = map[string]imaging.Format{
+	".bmp":  imaging.BMP,
+	".jpeg": imaging.JPEG,
+	".jpg":  imaging.JPEG,
+	".png":  imaging.PNG,
+	".tif":  imaging.TIFF,
+	".tiff": imaging.TIFF,
+}
+
+// The formats that a spec can convert an image to.
+var formatNames = map[string]imaging.Format{
+	"bmp":  imaging.BMP,
+	"jpeg": imaging.JPEG,
+	"jpg":  imaging.JPEG,
+	"png":  imaging.PNG,
+	"tif":  imaging.TIFF,
+	"tiff": imaging.TIFF,
+}
+
+var extensions = map[imaging.Format]string{
+	imaging.BMP:  ".bmp",
+	imaging.JPEG: ".jpg",
+	imaging.PNG:  ".png",
+	imaging.TIFF: ".tif",
+}
+
+var mediaTypes = map[imaging.Format]string{
+	imaging.BMP:  "image/bmp",
+	imaging.JPEG: "image/jpeg",
+	imaging.PNG:  "image/png",
+	imaging.TIFF: "image/tiff",
+}
+
+// The points of an image that fill keeps when cropping it.
+var anchors = map[string]imaging.Anchor{
+	"bottom":      imaging.Bottom,
+	"bottomleft":  imaging.BottomLeft,
+	"bottomright": imaging.BottomRight,
+	"center":      imaging.Center,
+	"left":        imaging.Left,
+	"right":       imaging.Right,
+	"top":         imaging.Top,
+	"topleft":     imaging.TopLeft,
+	"topright":    imaging.TopRight,
+}
+
+var (
+	dimensionsRegex = regexp.MustCompile(`^(\d*)x(\d*)$`)
+	qualityRegex    = regexp.MustCompile(`^q(\d+)$`)
+)
+
+// Image is an image in the built site: either an image from the input
+// directory as it is, or one that was processed from it.
+type Image struct {
+	// The contents of the file.
+	Contents []byte
+	// The height of the image in pixels.
+	Height int
+	// The media type of the image, for example image/jpeg.
+	MediaType string
+	// The path to the file relative to the output directory, using
+	// forward slashes.
+	SitePath string
+	// The path to the file in the input directory that the image was
+	// processed from.
+	SourcePath string
+	// The URL path of the file in the built site.
+	URL string
+	// The width of the image in pixels.
+	Width int
+}
+
+// Responsive is an image in several widths, for use in the srcset and
+// sizes attributes of img elements. The embedded Image is the widest of
+// them, for use in the src, width and height attributes.
+type Responsive struct {
+	*Image
+	// The image in each width, from narrowest to widest.
+	Images []*Image
+	// The value of the sizes attribute.
+	Sizes string
+	// The value of the srcset attribute.
+	Srcset string
+}
+
+// Processor resizes and converts images. Processed images are kept in a
+// cache directory, so that they are only processed again when the source
+// image or the processing changes.
+type Processor struct {
+	cacheDir string
+	keepExif bool
+	// the JPEG quality to use when a spec does not give one
+	quality int
+	// the images that have been processed, by site path
+	processed map[string]*Image
+}
+
+// New returns a Processor that keeps processed images in cacheDir. Images
+// are encoded as JPEG with quality, unless a spec gives another. If
+// keepExif is true, the EXIF metadata of JPEG images is copied to the
+// JPEG images that are processed from them; otherwise it is removed.
+func New(cacheDir string, quality int, keepExif bool) *Processor {
+	return &Processor{
+		cacheDir:  cacheDir,
+		keepExif:  keepExif,
+		quality:   quality,
+		processed: map[string]*Image{},
+	}
+}
+
+// Supported returns whether the file called name is an image that can be
+// processed.
+func Supported(name string) bool {
+	_, ok := formats[strings.ToLower(path.Ext(name))]
+	return ok
+}
+
+// operation is a way of processing an image.
+type operation struct {
+	// resize or fill
+	method string
+	width  int
+	height int
+	// the name of the anchor of fill
+	anchor  string
+	format  imaging.Format
+	quality int
+}
+
+// String returns a description of the operation for use in file names.
+func (op operation) String() string {
+	description := fmt.Sprintf("%dx%d_%s", op.width, op.height, op.method)
+	if op.method == "fill" {
+		description += "_" + op.anchor
+	}
+	if op.format == imaging.JPEG {
+		description += "_q" + strconv.Itoa(op.quality)
+	}
+	return description
+}
+
+// Original returns the image in source as it is, along with its
+// dimensions. The dimensions are those it is displayed with, so they are
+// swapped for JPEG images that their EXIF orientation rotates by 90
+// degrees.
+func (p *Processor) Original(source *asset.Asset) (*Image, error) {
+	format, err := formatOf(source.Name)
+	if err != nil {
+		return nil, err
+	}
+	config, _, err := image.DecodeConfig(bytes.NewReader(source.Contents))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %w", source.Name, err)
+	}
+	width, height := config.Width, config.Height
+	if format == imaging.JPEG && orientation(source.Contents) >= 5 {
+		width, height = height, width
+	}
+	originalImage := &Image{
+		Contents:   source.Contents,
+		Height:     height,
+		MediaType:  mediaTypes[format],
+		SitePath:   source.SitePath,
+		SourcePath: source.SourcePath,
+		URL:        source.URL,
+		Width:      width,
+	}
+	return originalImage, nil
+}
+
+// Resize returns the image in source resized as spec says. spec is made
+// up of the dimensions, such as 800x for a width of 800 pixels, x600 for
+// a height of 600 pixels or 800x600 for both, optionally followed by the
+// format to convert the image to (bmp, jpg, png or tif) and the JPEG
+// quality, such as q80. If only one dimension is given, the aspect ratio
+// of the image is kept.
+func (p *Processor) Resize(source *asset.Asset, spec string) (*Image, error) {
+	op, err := p.parseSpec(source, "resize", spec)
+	if err != nil {
+		return nil, err
+	}
+	if op.width == 0 && op.height == 0 {
+		return nil, fmt.Errorf("invalid image spec %q: must have a width, a height or both", spec)
+	}
+	return p.process(source, op)
+}
+
+// Fill returns the image in source resized and cropped to exactly the
+// dimensions in spec. spec is as for Resize, except that both dimensions
+// must be given, and it may also give the part of the image to keep when
+// cropping: center (the default), top, bottom, left, right, topleft,
+// topright, bottomleft or bottomright.
+func (p *Processor) Fill(source *asset.Asset, spec string) (*Image, error) {
+	op, err := p.parseSpec(source, "fill", spec)
+	if err != nil {
+		return nil, err
+	}
+	if op.width == 0 || op.height == 0 {
+		return nil, fmt.Errorf("invalid image spec %q: must have both a width and a height", spec)
+	}
+	return p.process(source, op)
+}
+
+// Srcset returns the image in source resized to each of widths, with
+// sizes as the value of the sizes attribute. Images are never made wider
+// than the original: widths that are at least as wide as it are replaced
+// by the width of the original.
+func (p *Processor) Srcset(source *asset.Asset, widths []int, sizes string) (*Responsive, error) {
+	originalImage, err := p.Original(source)
+	if err != nil {
+		return nil, err
+	}
+	format, err := formatOf(source.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	sortedWidths := slices.Clone(widths)
+	slices.Sort(sortedWidths)
+	targetWidths := []int{}
+	for _, width := range slices.Compact(sortedWidths) {
+		if width >= originalImage.Width {
+			targetWidths = append(targetWidths, originalImage.Width)
+			break
+		}
+		targetWidths = append(targetWidths, width)
+	}
+	if len(targetWidths) == 0 {
+		return nil, errors.New("no widths to resize to")
+	}
+
+	responsive := &Responsive{Sizes: sizes}
+	candidates := make([]string, 0, len(targetWidths))
+	for _, width := range targetWidths {
+		op := operation{method: "resize", width: width, format: format, quality: p.quality}
+		resizedImage, err := p.process(source, op)
+		if err != nil {
+			return nil, err
+		}
+		responsive.Images = append(responsive.Images, resizedImage)
+		candidates = append(candidates, fmt.Sprintf("%s %dw", resizedImage.URL, resizedImage.Width))
+	}
+	responsive.Image = responsive.Images[len(responsive.Images)-1]
+	responsive.Srcset = strings.Join(candidates, ", ")
+	return responsive, nil
+}
+
+// Processed returns the images that have been processed, ordered by site
+// path.
+func (p *Processor) Processed() []*Image {
+	processedImages := make([]*Image, 0, len(p.processed))
+	for _, processedImage := range p.processed {
+		processedImages = append(processedImages, processedImage)
+	}
+	slices.SortFunc(processedImages, func(a, b *Image) int {
+		return strings.Compare(a.SitePath, b.SitePath)
+	})
+	return processedImages
+}
+
+// parseSpec parses the spec of an operation of method on source.
+func (p *Processor) parseSpec(source *asset.Asset, method, spec string) (operation, error) {
+	format, err := formatOf(source.Name)
+	if err != nil {
+		return operation{}, err
+	}
+	op := operation{method: method, format: format, quality: p.quality}
+	if method == "fill" {
+		op.anchor = "center"
+	}
+	for _, field := range strings.Fields(spec) {
+		field = strings.ToLower(field)
+		if matches := dimensionsRegex.FindStringSubmatch(field); matches != nil {
+			op.width, _ = strconv.Atoi(matches[1])
+			op.height, _ = strconv.Atoi(matches[2])
+		} else if matches := qualityRegex.FindStringSubmatch(field); matches != nil {
+			op.quality, _ = strconv.Atoi(matches[1])
+			if op.quality < 1 || op.quality > 100 {
+				return operation{}, fmt.Errorf("invalid image spec %q: quality must be from 1 to 100", spec)
+			}
+		} else if format, ok := formatNames[field]; ok {
+			op.format = format
+		} else if _, ok := anchors[field]; ok && method == "fill" {
+			op.anchor = field
+		} else {
+			return operation{}, fmt.Errorf("invalid image spec %q: unknown option %q", spec, field)
+		}
+	}
+	return op, nil
+}
+
+// process returns the image in source processed by op, from the cache if
+// it has been processed before.
+func (p *Processor) process(source *asset.Asset, op operation) (*Image, error) {
+	hash := sha256.Sum256([]byte(fmt.Sprintf("%s %s %t", source.Integrity, op, p.keepExif)))
+	sitePath := strings.TrimSuffix(source.Name, path.Ext(source.Name)) +
+		"_" + op.String() + "_" + hex.EncodeToString(hash[:hashLength]) + extensions[op.format]
+	if processedImage, ok := p.processed[sitePath]; ok {
+		return processedImage, nil
+	}
+
+	cachePath := filepath.Join(p.cacheDir, path.Base(sitePath))
+	contents, err := os.ReadFile(cachePath)
+	if errors.Is(err, fs.ErrNotExist) {
+		contents, err = p.encode(source, op)
+		if err != nil {
+			return nil, err
+		}
+		if err := os.MkdirAll(p.cacheDir, 0o755); err != nil {
+			return nil, fmt.Errorf("failed to create image cache dir: %w", err)
+		}
+		if err := os.WriteFile(cachePath, contents, 0o644); err != nil {
+			return nil, fmt.Errorf("failed to cache processed image: %w", err)
+		}
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read cached image: %w", err)
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(contents))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode processed image %s: %w", cachePath, err)
+	}
+	processedImage := &Image{
+		Contents:   contents,
+		Height:     config.Height,
+		MediaType:  mediaTypes[op.format],
+		SitePath:   sitePath,
+		SourcePath: source.SourcePath,
+		URL:        "/" + sitePath,
+		Width:      config.Width,
+	}
+	p.processed[sitePath] = processedImage
+	return processedImage, nil
+}
+
+// encode processes the image in source by op and returns the encoded
+// result.
+func (p *Processor) encode(source *asset.Asset, op operation) ([]byte, error) {
+	decoded, err := imaging.Decode(bytes.NewReader(source.Contents), imaging.AutoOrientation(true))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %w", source.Name, err)
+	}
+	switch op.method {
+	case "resize":
+		decoded = imaging.Resize(decoded, op.width, op.height, imaging.Lanczos)
+	case "fill":
+		decoded = imaging.Fill(decoded, op.width, op.height, anchors[op.anchor], imaging.Lanczos)
+	}
+
+	encoded := &bytes.Buffer{}
+	if err := imaging.Encode(encoded, decoded, op.format, imaging.JPEGQuality(op.quality)); err != nil {
+		return nil, fmt.Errorf("failed to encode %s: %w", source.Name, err)
+	}
+	contents := encoded.Bytes()
+	if p.keepExif && op.format == imaging.JPEG {
+		if segment := exifSegment(source.Contents); segment != nil {
+			contents = withExif(contents, segment)
+		}
+	}
+	return contents, nil
+}
+
+// formatOf returns the format of the image called name.
+func formatOf(name string) (imaging.Format, error) {
+	format, ok := formats[strings.ToLower(path.Ext(name))]
+	if !ok {
+		return 0, fmt.Errorf("%s is not an image that can be processed", name)
+	}
+	return format, nil
+}
@@ -0,0 +1,224 @@
+package images
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/adamkpickering/jenny/internal/asset"
+)
+
+// testJPEG returns a JPEG of the given dimensions, with an EXIF segment
+// that has orientation if it is not 0.
+func testJPEG(t *testing.T, width, height, orientation int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for x := range width {
+		for y := range height {
+			img.Set(x, y, color.RGBA{uint8(x), uint8(y), 0, 255})
+		}
+	}
+	encoded := &bytes.Buffer{}
+	if err := jpeg.Encode(encoded, img, nil); err != nil {
+		t.Fatalf("failed to encode test image: %s", err)
+	}
+	if orientation == 0 {
+		return encoded.Bytes()
+	}
+	payload := []byte("Exif\x00\x00MM\x00\x2a\x00\x00\x00\x08")
+	payload = binary.BigEndian.AppendUint16(payload, 1)
+	payload = append(payload, 0x01, 0x12, 0x00, 0x03, 0x00, 0x00, 0x00, 0x01)
+	payload = binary.BigEndian.AppendUint16(payload, uint16(orientation))
+	payload = append(payload, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00)
+	segment := []byte{0xff, markerAPP1}
+	segment = binary.BigEndian.AppendUint16(segment, uint16(len(payload)+2))
+	segment = append(segment, payload...)
+	return withExifUnchanged(encoded.Bytes(), segment)
+}
+
+// withExifUnchanged inserts segment into contents without changing it.
+func withExifUnchanged(contents, segment []byte) []byte {
+	return append(append(append([]byte{}, contents[:2]...), segment...), contents[2:]...)
+}
+
+func testAsset(t *testing.T, name string, contents []byte) *asset.Asset {
+	t.Helper()
+	return asset.New(filepath.Join("input", name), name, contents, false)
+}
+
+func TestResize(t *testing.T) {
+	source := testAsset(t, "photos/a.jpg", testJPEG(t, 200, 100, 0))
+
+	cases := []struct {
+		spec   string
+		width  int
+		height int
+		ext    string
+	}{
+		{"50x", 50, 25, ".jpg"},
+		{"x20", 40, 20, ".jpg"},
+		{"50x50 png", 50, 50, ".png"},
+	}
+	for _, c := range cases {
+		t.Run("should resize to "+c.spec, func(t *testing.T) {
+			processor := New(t.TempDir(), 75, false)
+			resized, err := processor.Resize(source, c.spec)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if resized.Width != c.width || resized.Height != c.height {
+				t.Errorf("got %dx%d but expected %dx%d", resized.Width, resized.Height, c.width, c.height)
+			}
+			if !strings.HasPrefix(resized.URL, "/photos/a_") || filepath.Ext(resized.URL) != c.ext {
+				t.Errorf("got unexpected URL %s", resized.URL)
+			}
+			if len(processor.Processed()) != 1 {
+				t.Errorf("got %d processed images but expected 1", len(processor.Processed()))
+			}
+		})
+	}
+
+	for _, spec := range []string{"", "x", "50x q0", "50x webp", "50x top"} {
+		t.Run("should return error for spec "+spec, func(t *testing.T) {
+			processor := New(t.TempDir(), 75, false)
+			if _, err := processor.Resize(source, spec); err == nil {
+				t.Errorf("did not get error when we should have")
+			}
+		})
+	}
+
+	t.Run("should reuse cached images", func(t *testing.T) {
+		cacheDir := t.TempDir()
+		resized, err := New(cacheDir, 75, false).Resize(source, "50x")
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		cachePath := filepath.Join(cacheDir, filepath.Base(resized.SitePath))
+		cached := testJPEG(t, 10, 10, 0)
+		if err := os.WriteFile(cachePath, cached, 0o644); err != nil {
+			t.Fatalf("failed to write cached image: %s", err)
+		}
+		resized, err = New(cacheDir, 75, false).Resize(source, "50x")
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if !bytes.Equal(resized.Contents, cached) || resized.Width != 10 {
+			t.Errorf("did not get cached image")
+		}
+	})
+}
+
+func TestFill(t *testing.T) {
+	source := testAsset(t, "a.jpg", testJPEG(t, 200, 100, 0))
+
+	t.Run("should resize and crop to the dimensions", func(t *testing.T) {
+		filled, err := New(t.TempDir(), 75, false).Fill(source, "30x30 topleft")
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if filled.Width != 30 || filled.Height != 30 {
+			t.Errorf("got %dx%d but expected 30x30", filled.Width, filled.Height)
+		}
+	})
+
+	t.Run("should return error without both dimensions", func(t *testing.T) {
+		if _, err := New(t.TempDir(), 75, false).Fill(source, "30x"); err == nil {
+			t.Errorf("did not get error when we should have")
+		}
+	})
+}
+
+func TestSrcset(t *testing.T) {
+	source := testAsset(t, "a.jpg", testJPEG(t, 200, 100, 0))
+	responsive, err := New(t.TempDir(), 75, false).Srcset(source, []int{400, 50, 100, 50}, "100vw")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	t.Run("should not make images wider than the original", func(t *testing.T) {
+		widths := []int{}
+		for _, resized := range responsive.Images {
+			widths = append(widths, resized.Width)
+		}
+		if len(widths) != 3 || widths[0] != 50 || widths[1] != 100 || widths[2] != 200 {
+			t.Errorf("got widths %v but expected [50 100 200]", widths)
+		}
+	})
+
+	t.Run("should use the widest image as the fallback", func(t *testing.T) {
+		if responsive.Image != responsive.Images[2] {
+			t.Errorf("got %s but expected %s", responsive.URL, responsive.Images[2].URL)
+		}
+	})
+
+	t.Run("should list every image in srcset", func(t *testing.T) {
+		expected := responsive.Images[0].URL + " 50w, " + responsive.Images[1].URL + " 100w, " + responsive.Images[2].URL + " 200w"
+		if responsive.Srcset != expected {
+			t.Errorf("got %q but expected %q", responsive.Srcset, expected)
+		}
+	})
+}
+
+func TestExif(t *testing.T) {
+	source := testAsset(t, "a.jpg", testJPEG(t, 20, 10, 6))
+
+	t.Run("should report dimensions as displayed", func(t *testing.T) {
+		original, err := New(t.TempDir(), 75, false).Original(source)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if original.Width != 10 || original.Height != 20 {
+			t.Errorf("got %dx%d but expected 10x20", original.Width, original.Height)
+		}
+	})
+
+	t.Run("should rotate and strip EXIF by default", func(t *testing.T) {
+		resized, err := New(t.TempDir(), 75, false).Resize(source, "5x")
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if resized.Height != 10 {
+			t.Errorf("got height %d but expected 10", resized.Height)
+		}
+		if exifSegment(resized.Contents) != nil {
+			t.Errorf("processed image has EXIF segment but should not")
+		}
+	})
+
+	t.Run("should keep EXIF with reset orientation", func(t *testing.T) {
+		resized, err := New(t.TempDir(), 75, true).Resize(source, "5x")
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if exifSegment(resized.Contents) == nil {
+			t.Fatalf("processed image has no EXIF segment")
+		}
+		if got := orientation(resized.Contents); got != 1 {
+			t.Errorf("got orientation %d but expected 1", got)
+		}
+		if got := orientation(source.Contents); got != 6 {
+			t.Errorf("source orientation was changed to %d", got)
+		}
+	})
+}
+
+func TestSupported(t *testing.T) {
+	cases := map[string]bool{
+		"a.jpg":     true,
+		"a/b.JPEG":  true,
+		"a.png":     true,
+		"a.gif":     false,
+		"style.css": false,
+	}
+	for name, expected := range cases {
+		if got := Supported(name); got != expected {
+			t.Errorf("got %t for %s but expected %t", got, name, expected)
+		}
+	}
+}
@@ -0,0 +1,210 @@
+package memfs
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FS is an in-memory filesystem. Directories exist implicitly as the
+// parents of files. It is safe for concurrent use.
+type FS struct {
+	lock  sync.RWMutex
+	files map[string]*fileData
+	// the number of files in each directory and its subdirectories, so
+	// that directories do not have to be found by scanning files
+	dirs map[string]int
+}
+
+type fileData struct {
+	contents []byte
+	modTime  time.Time
+}
+
+func New() *FS {
+	fsys := &FS{
+		files: make(map[string]*fileData),
+		dirs:  make(map[string]int),
+	}
+	return fsys
+}
+
+// WriteFile creates or replaces the file called name. name must be a
+// valid path as described by fs.ValidPath, and must not be the same as
+// the path of a directory.
+func (fsys *FS) WriteFile(name string, contents []byte) error {
+	if !fs.ValidPath(name) || name == "." {
+		return &fs.PathError{Op: "write", Path: name, Err: fs.ErrInvalid}
+	}
+	fsys.lock.Lock()
+	defer fsys.lock.Unlock()
+	if fsys.isDir(name) {
+		return &fs.PathError{Op: "write", Path: name, Err: errors.New("is a directory")}
+	}
+	for dir := path.Dir(name); dir != "."; dir = path.Dir(dir) {
+		if _, ok := fsys.files[dir]; ok {
+			return &fs.PathError{Op: "write", Path: name, Err: errors.New("parent is a file")}
+		}
+	}
+	if _, ok := fsys.files[name]; !ok {
+		for dir := path.Dir(name); dir != "."; dir = path.Dir(dir) {
+			fsys.dirs[dir]++
+		}
+	}
+	fsys.files[name] = &fileData{
+		contents: slices.Clone(contents),
+		modTime:  time.Now(),
+	}
+	return nil
+}
+
+// Remove removes the file called name.
+func (fsys *FS) Remove(name string) error {
+	fsys.lock.Lock()
+	defer fsys.lock.Unlock()
+	if _, ok := fsys.files[name]; !ok {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrNotExist}
+	}
+	delete(fsys.files, name)
+	for dir := path.Dir(name); dir != "."; dir = path.Dir(dir) {
+		fsys.dirs[dir]--
+		if fsys.dirs[dir] == 0 {
+			delete(fsys.dirs, dir)
+		}
+	}
+	return nil
+}
+
+// Exists returns whether there is a file or directory called name.
+func (fsys *FS) Exists(name string) bool {
+	fsys.lock.RLock()
+	defer fsys.lock.RUnlock()
+	_, ok := fsys.files[name]
+	return ok || fsys.isDir(name)
+}
+
+func (fsys *FS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	fsys.lock.RLock()
+	defer fsys.lock.RUnlock()
+
+	if data, ok := fsys.files[name]; ok {
+		file := &file{
+			info:   fileInfo{name: path.Base(name), size: int64(len(data.contents)), modTime: data.modTime},
+			Reader: bytes.NewReader(data.contents),
+		}
+		return file, nil
+	}
+	if fsys.isDir(name) {
+		dir := &dir{
+			info:    fileInfo{name: path.Base(name), mode: fs.ModeDir | 0o755},
+			entries: fsys.readDir(name),
+		}
+		return dir, nil
+	}
+	return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+}
+
+func (fsys *FS) ReadFile(name string) ([]byte, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: fs.ErrInvalid}
+	}
+	fsys.lock.RLock()
+	defer fsys.lock.RUnlock()
+	data, ok := fsys.files[name]
+	if !ok {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: fs.ErrNotExist}
+	}
+	return slices.Clone(data.contents), nil
+}
+
+// isDir must be called with the lock held.
+func (fsys *FS) isDir(name string) bool {
+	_, ok := fsys.dirs[name]
+	return ok || name == "."
+}
+
+// readDir must be called with the lock held.
+func (fsys *FS) readDir(name string) []fs.DirEntry {
+	prefix := name + "/"
+	if name == "." {
+		prefix = ""
+	}
+	entries := map[string]fs.DirEntry{}
+	for filePath, data := range fsys.files {
+		if !strings.HasPrefix(filePath, prefix) {
+			continue
+		}
+		entryName, rest, isDir := strings.Cut(strings.TrimPrefix(filePath, prefix), "/")
+		if isDir {
+			entries[entryName] = fs.FileInfoToDirEntry(fileInfo{name: entryName, mode: fs.ModeDir | 0o755})
+		} else if rest == "" {
+			entries[entryName] = fs.FileInfoToDirEntry(fileInfo{name: entryName, size: int64(len(data.contents)), modTime: data.modTime})
+		}
+	}
+	sortedEntries := make([]fs.DirEntry, 0, len(entries))
+	for _, entry := range entries {
+		sortedEntries = append(sortedEntries, entry)
+	}
+	slices.SortFunc(sortedEntries, func(a, b fs.DirEntry) int {
+		return strings.Compare(a.Name(), b.Name())
+	})
+	return sortedEntries
+}
+
+type fileInfo struct {
+	name    string
+	size    int64
+	mode    fs.FileMode
+	modTime time.Time
+}
+
+func (info fileInfo) Name() string       { return info.name }
+func (info fileInfo) Size() int64        { return info.size }
+func (info fileInfo) Mode() fs.FileMode  { return info.mode | 0o444 }
+func (info fileInfo) ModTime() time.Time { return info.modTime }
+func (info fileInfo) IsDir() bool        { return info.mode.IsDir() }
+func (info fileInfo) Sys() any           { return nil }
+
+type file struct {
+	*bytes.Reader
+	info fileInfo
+}
+
+func (file *file) Stat() (fs.FileInfo, error) { return file.info, nil }
+func (file *file) Close() error               { return nil }
+
+type dir struct {
+	info    fileInfo
+	entries []fs.DirEntry
+	offset  int
+}
+
+func (dir *dir) Stat() (fs.FileInfo, error) { return dir.info, nil }
+func (dir *dir) Close() error               { return nil }
+
+func (dir *dir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: dir.info.name, Err: errors.New("is a directory")}
+}
+
+func (dir *dir) ReadDir(count int) ([]fs.DirEntry, error) {
+	remaining := dir.entries[dir.offset:]
+	if count <= 0 {
+		dir.offset = len(dir.entries)
+		return remaining, nil
+	}
+	if len(remaining) == 0 {
+		return nil, io.EOF
+	}
+	count = min(count, len(remaining))
+	dir.offset += count
+	return remaining[:count], nil
+}
@@ -0,0 +1,86 @@
+package memfs
+
+import (
+	"testing"
+	"testing/fstest"
+)
+
+func TestFS(t *testing.T) {
+	t.Run("should behave like a filesystem", func(t *testing.T) {
+		fsys := New()
+		files := map[string]string{
+			"index.html":            "<html></html>",
+			"static/style.css":      "body {}",
+			"posts/my-trip/a.jpg":   "jpeg",
+			"posts/my-trip/b/c.txt": "text",
+		}
+		for name, contents := range files {
+			if err := fsys.WriteFile(name, []byte(contents)); err != nil {
+				t.Fatalf("unexpected error in WriteFile(): %s", err)
+			}
+		}
+		if err := fstest.TestFS(fsys, "index.html", "static/style.css", "posts/my-trip/a.jpg", "posts/my-trip/b/c.txt"); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("should replace existing file", func(t *testing.T) {
+		fsys := New()
+		if err := fsys.WriteFile("a.txt", []byte("one")); err != nil {
+			t.Fatalf("unexpected error in WriteFile(): %s", err)
+		}
+		if err := fsys.WriteFile("a.txt", []byte("two")); err != nil {
+			t.Fatalf("unexpected error in WriteFile(): %s", err)
+		}
+		contents, err := fsys.ReadFile("a.txt")
+		if err != nil {
+			t.Fatalf("unexpected error in ReadFile(): %s", err)
+		}
+		if string(contents) != "two" {
+			t.Errorf("got contents %q but expected %q", contents, "two")
+		}
+	})
+
+	t.Run("should not allow files and directories with the same path", func(t *testing.T) {
+		fsys := New()
+		if err := fsys.WriteFile("a/b.txt", []byte("b")); err != nil {
+			t.Fatalf("unexpected error in WriteFile(): %s", err)
+		}
+		if err := fsys.WriteFile("a", []byte("a")); err == nil {
+			t.Errorf("did not get error when writing file with path of directory")
+		}
+		if err := fsys.WriteFile("a/b.txt/c.txt", []byte("c")); err == nil {
+			t.Errorf("did not get error when writing file under a file")
+		}
+	})
+
+	t.Run("should remove directories with their last file", func(t *testing.T) {
+		fsys := New()
+		for _, name := range []string{"a/b/c.txt", "a/d.txt"} {
+			if err := fsys.WriteFile(name, []byte(name)); err != nil {
+				t.Fatalf("unexpected error in WriteFile(): %s", err)
+			}
+		}
+		if err := fsys.Remove("a/b/c.txt"); err != nil {
+			t.Fatalf("unexpected error in Remove(): %s", err)
+		}
+		if fsys.Exists("a/b") {
+			t.Errorf("directory a/b exists after its last file was removed")
+		}
+		if !fsys.Exists("a") {
+			t.Errorf("directory a does not exist but still has a file")
+		}
+		if err := fsys.WriteFile("a/b", []byte("b")); err != nil {
+			t.Errorf("unexpected error writing file with path of removed directory: %s", err)
+		}
+	})
+
+	t.Run("should reject invalid paths", func(t *testing.T) {
+		fsys := New()
+		for _, name := range []string{"/abs.txt", "../up.txt", ".", ""} {
+			if err := fsys.WriteFile(name, nil); err == nil {
+				t.Errorf("did not get error when writing %q", name)
+			}
+		}
+	})
+}
@@ -0,0 +1,86 @@
+package minify
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+
+	tdewolff "github.com/tdewolff/minify/v2"
+	"github.com/tdewolff/minify/v2/css"
+	"github.com/tdewolff/minify/v2/html"
+	"github.com/tdewolff/minify/v2/js"
+	"github.com/tdewolff/minify/v2/json"
+	"github.com/tdewolff/minify/v2/svg"
+)
+
+// The media types of the files that can be minified.
+const (
+	CSS        = "text/css"
+	HTML       = "text/html"
+	JavaScript = "text/javascript"
+	JSON       = "application/json"
+	SVG        = "image/svg+xml"
+)
+
+// MediaTypes lists the media types of the files that can be minified.
+var MediaTypes = []string{JSON, SVG, CSS, HTML, JavaScript}
+
+// mediaTypesByExtension maps the extensions of files that can be minified
+// to their media types. This does not use the mime package, since the
+// media types it returns depend on the system.
+var mediaTypesByExtension = map[string]string{
+	".css":  CSS,
+	".htm":  HTML,
+	".html": HTML,
+	".js":   JavaScript,
+	".json": JSON,
+	".mjs":  JavaScript,
+	".svg":  SVG,
+}
+
+// minifier minifies files of each of MediaTypes. The minifiers of CSS,
+// JavaScript and SVG are also used for the stylesheets, scripts and
+// images in HTML documents.
+var minifier = newMinifier()
+
+func newMinifier() *tdewolff.M {
+	m := tdewolff.New()
+	m.AddFunc(CSS, css.Minify)
+	// The document and end tags are kept so that jenny serve can find
+	// where to insert the reload script.
+	m.Add(HTML, &html.Minifier{
+		KeepDocumentTags:    true,
+		KeepEndTags:         true,
+		KeepSpecialComments: true,
+	})
+	// Scripts in HTML documents can have any of the media types of
+	// JavaScript.
+	m.AddFuncRegexp(regexp.MustCompile(`^(application|text)/(x-)?(java|ecma)script$`), js.Minify)
+	m.AddFunc(JSON, json.Minify)
+	m.AddFunc(SVG, svg.Minify)
+	return m
+}
+
+// MediaType returns the media type of the file at name if it is one that
+// can be minified, and "" otherwise.
+func MediaType(name string) string {
+	return mediaTypesByExtension[strings.ToLower(path.Ext(name))]
+}
+
+// Minify returns contents, which is a file of mediaType, with comments,
+// unnecessary whitespace and anything else that does not change how it
+// behaves removed.
+func Minify(mediaType string, contents []byte) ([]byte, error) {
+	if !slices.Contains(MediaTypes, mediaType) {
+		return nil, fmt.Errorf("cannot minify files of type %s", mediaType)
+	}
+	// The SVG minifier collapses whitespace in text even where
+	// xml:space="preserve" says that it is significant.
+	if mediaType == SVG && bytes.Contains(contents, []byte("xml:space")) {
+		return contents, nil
+	}
+	return minifier.Bytes(mediaType, contents)
+}
@@ -0,0 +1,130 @@
+package minify
+
+import "testing"
+
+func TestMinify(t *testing.T) {
+	cases := []struct {
+		description string
+		mediaType   string
+		input       string
+		expected    string
+	}{
+		{
+			"should remove comments and whitespace from HTML",
+			HTML,
+			"<!DOCTYPE html>\n<html lang=\"en\">\n <head>\n  <!-- comment -->\n  <link rel=\"stylesheet\"\n        href=\"/a.css\">\n </head>\n <body><p>a  <b>b</b>  c</p></body>\n</html>\n",
+			"<!doctype html><html lang=en><head><link rel=stylesheet href=/a.css></head><body><p>a <b>b</b> c</p></body></html>",
+		},
+		{
+			"should keep whitespace in pre and textarea elements",
+			HTML,
+			"<pre>  a\n\n  <code>b  c</code></pre>\n\n<textarea>  x  </textarea>",
+			"<pre>  a\n\n  <code>b  c</code></pre><textarea>  x  </textarea>",
+		},
+		{
+			"should minify scripts and stylesheets in HTML",
+			HTML,
+			"<style>\n  a { color : red }\n</style>\n<script>\n  let a  = 1\n</script>",
+			"<style>a{color:red}</style><script>let a=1</script>",
+		},
+		{
+			"should keep conditional comments in HTML",
+			HTML,
+			"<!--[if IE]><p>IE</p><![endif]--><!-- x -->",
+			"<!--[if IE]><p>IE</p><![endif]-->",
+		},
+		{
+			"should remove unnecessary whitespace from CSS",
+			CSS,
+			"/* comment */\n@media screen and (max-width: 600px) {\n  a:hover , .b > c  { color : red ; margin: calc(1px + 2px) 0 !important; }\n}\n",
+			"@media screen and (max-width:600px){a:hover,.b>c{color:red;margin:calc(1px + 2px)0!important}}",
+		},
+		{
+			"should keep descendant combinators and strings in CSS",
+			CSS,
+			".a .b::before { content: \"a  b\" ; }\n",
+			".a .b::before{content:\"a  b\"}",
+		},
+		{
+			"should remove comments and unnecessary whitespace from JavaScript",
+			JavaScript,
+			"// comment\nconst a = 1;\n/* block */\nfunction f(x, y) {\n  return x + y;\n}\n",
+			"const a=1;function f(e,t){return e+t}",
+		},
+		{
+			"should keep statements that end at line breaks apart in JavaScript",
+			JavaScript,
+			"let a = b\n++c\n",
+			"let a=b;++c",
+		},
+		{
+			"should keep regular expressions after parentheses in JavaScript",
+			JavaScript,
+			"if (x) /a  b/.test(s)",
+			"x&&/a  b/.test(s)",
+		},
+		{
+			"should keep regular expressions and template literals in JavaScript",
+			JavaScript,
+			"const r = / a  b /g, d = a / b / c;\nlet s = `a  ${ b  +  `c  ${d}` }  e`;\n",
+			"const r=/ a  b /g,d=a/b/c;let s=`a  ${b+`c  ${d}`}  e`",
+		},
+		{
+			"should compact JSON",
+			JSON,
+			"{\n  \"a\": [1, 2],\n  \"b\": \"c  d\"\n}\n",
+			"{\"a\":[1,2],\"b\":\"c  d\"}",
+		},
+		{
+			"should remove comments and whitespace from SVG",
+			SVG,
+			"<?xml version=\"1.0\"?>\n<!-- Generator -->\n<svg xmlns=\"http://www.w3.org/2000/svg\"\n     viewBox=\"0 0 10 10\">\n  <g  fill=\"red\">\n    <text>a  b</text>\n  </g>\n</svg>\n",
+			"<svg xmlns=\"http://www.w3.org/2000/svg\" viewBox=\"0 0 10 10\"><g fill=\"red\"><text>a b</text></g></svg>",
+		},
+		{
+			"should not minify SVG with preserved whitespace",
+			SVG,
+			"<svg xmlns=\"http://www.w3.org/2000/svg\">\n  <text xml:space=\"preserve\">a    b</text>\n</svg>\n",
+			"<svg xmlns=\"http://www.w3.org/2000/svg\">\n  <text xml:space=\"preserve\">a    b</text>\n</svg>\n",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			minified, err := Minify(c.mediaType, []byte(c.input))
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if string(minified) != c.expected {
+				t.Errorf("got %q but expected %q", minified, c.expected)
+			}
+		})
+	}
+
+	t.Run("should return error for invalid JSON", func(t *testing.T) {
+		if _, err := Minify(JSON, []byte("{")); err == nil {
+			t.Errorf("did not get error when we should have")
+		}
+	})
+
+	t.Run("should return error for other media types", func(t *testing.T) {
+		if _, err := Minify("image/png", []byte("")); err == nil {
+			t.Errorf("did not get error when we should have")
+		}
+	})
+}
+
+func TestMediaType(t *testing.T) {
+	cases := map[string]string{
+		"static/style.css": CSS,
+		"index.HTML":       HTML,
+		"app.mjs":          JavaScript,
+		"data.json":        JSON,
+		"logo.svg":         SVG,
+		"photo.jpg":        "",
+	}
+	for name, expected := range cases {
+		if got := MediaType(name); got != expected {
+			t.Errorf("got %q for %s but expected %q", got, name, expected)
+		}
+	}
+}
@@ -1,46 +1,206 @@
 package notify
 
+import (
+	"slices"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// MessageType identifies what a Message is about.
+type MessageType string
+
+const (
+	// Reload means that the site was rebuilt successfully.
+	Reload MessageType = "reload"
+	// Error means that the site failed to build.
+	Error MessageType = "error"
+	// CSS means that the site was rebuilt successfully, and that only the
+	// stylesheets in Paths changed.
+	CSS MessageType = "css"
+)
+
+// Message is a notification that is sent to subscribers.
+type Message struct {
+	Type  MessageType `json:"type"`
+	Error *BuildError `json:"error,omitempty"`
+	// The paths, relative to the root of the site, of the stylesheets
+	// that changed.
+	Paths []string `json:"paths,omitempty"`
+}
+
+// BuildError describes a failed build in a form that can be shown to the
+// user.
+type BuildError struct {
+	Message    string `json:"message"`
+	SourcePath string `json:"sourcePath,omitempty"`
+	Template   string `json:"template,omitempty"`
+	Line       int    `json:"line,omitempty"`
+}
+
+// merge combines a message that has not been received yet with a newer
+// message, so that subscribers only receive the result. Each message
+// describes the state of the site, so the newer message usually wins. The
+// exception is a CSS message: it only describes part of the state, so
+// it is combined with the pending message.
+func (message Message) merge(newer Message) Message {
+	if newer.Type != CSS {
+		return newer
+	}
+	if message.Type != CSS {
+		// The pending message required a full reload (or an error to be
+		// cleared), and that still needs to happen.
+		return Message{Type: Reload}
+	}
+	merged := Message{
+		Type:  CSS,
+		Paths: slices.Clone(message.Paths),
+	}
+	for _, path := range newer.Paths {
+		if !slices.Contains(merged.Paths, path) {
+			merged.Paths = append(merged.Paths, path)
+		}
+	}
+	return merged
+}
+
 // Notifier allows one goroutine to send a notification to one or more other
-// goroutines.
+// goroutines. It is safe for concurrent use. Sending never blocks: each
+// subscriber has a buffer of one message, and if a subscriber has not
+// received the previous message by the time a new one is sent, the two are
+// merged.
 type Notifier struct {
-	channels map[string]chan struct{}
+	lock          sync.Mutex
+	subscriptions map[string]*Subscription
+	lastID        uint64
+	closed        bool
+}
+
+// Subscription receives the messages sent by a Notifier.
+type Subscription struct {
+	SubscriberInfo
+	// The channel on which messages are received. It is closed when the
+	// subscription ends.
+	Messages <-chan Message
+	channel  chan Message
+	notifier *Notifier
+	sequence uint64
+}
+
+// SubscriberInfo describes a subscriber.
+type SubscriberInfo struct {
+	// Generated by the Notifier, and unique among its subscriptions.
+	ID string `json:"id"`
+	// The page that the subscriber is viewing, if it is a browser.
+	Page string `json:"page,omitempty"`
+	// The address that the subscriber connected from.
+	RemoteAddr string `json:"remoteAddr,omitempty"`
+	// When the subscription started.
+	Since time.Time `json:"since"`
 }
 
 func New() *Notifier {
 	notifier := &Notifier{
-		channels: make(map[string]chan struct{}),
+		subscriptions: make(map[string]*Subscription),
 	}
 	return notifier
 }
 
-func (notifier *Notifier) Register(id string) {
-	notifier.channels[id] = make(chan struct{})
-}
+// Subscribe creates a new subscription. The ID and Since fields of info
+// are set by the Notifier. Unsubscribe must be called on the returned
+// Subscription once it is no longer needed.
+func (notifier *Notifier) Subscribe(info SubscriberInfo) *Subscription {
+	notifier.lock.Lock()
+	defer notifier.lock.Unlock()
 
-func (notifier *Notifier) Notify() {
-	for _, channel := range notifier.channels {
-		channel <- struct{}{}
+	notifier.lastID++
+	info.ID = strconv.FormatUint(notifier.lastID, 10)
+	info.Since = time.Now()
+	channel := make(chan Message, 1)
+	subscription := &Subscription{
+		SubscriberInfo: info,
+		Messages:       channel,
+		channel:        channel,
+		notifier:       notifier,
+		sequence:       notifier.lastID,
+	}
+	if notifier.closed {
+		close(channel)
+		return subscription
 	}
+	notifier.subscriptions[info.ID] = subscription
+	return subscription
 }
 
-func (notifier *Notifier) Get(id string) chan struct{} {
-	channel, ok := notifier.channels[id]
-	if ok {
-		return channel
+// Unsubscribe ends the subscription and closes its channel. It is safe to
+// call more than once.
+func (subscription *Subscription) Unsubscribe() {
+	notifier := subscription.notifier
+	notifier.lock.Lock()
+	defer notifier.lock.Unlock()
+	notifier.unsubscribe(subscription.ID)
+}
+
+// Subscribers returns information about the current subscribers, in the
+// order in which they subscribed.
+func (notifier *Notifier) Subscribers() []SubscriberInfo {
+	notifier.lock.Lock()
+	defer notifier.lock.Unlock()
+
+	subscriptions := make([]*Subscription, 0, len(notifier.subscriptions))
+	for _, subscription := range notifier.subscriptions {
+		subscriptions = append(subscriptions, subscription)
 	}
-	return nil
+	sort.Slice(subscriptions, func(i, j int) bool {
+		return subscriptions[i].sequence < subscriptions[j].sequence
+	})
+	subscribers := make([]SubscriberInfo, 0, len(subscriptions))
+	for _, subscription := range subscriptions {
+		subscribers = append(subscribers, subscription.SubscriberInfo)
+	}
+	return subscribers
 }
 
-func (notifier *Notifier) Close(id string) {
-	channel, ok := notifier.channels[id]
-	if ok {
-		close(channel)
-		delete(notifier.channels, id)
+// Notify sends message to every subscriber without blocking.
+func (notifier *Notifier) Notify(message Message) {
+	notifier.lock.Lock()
+	defer notifier.lock.Unlock()
+
+	for _, subscription := range notifier.subscriptions {
+		channel := subscription.channel
+		select {
+		case channel <- message:
+		default:
+			// A message is pending. Since messages are only sent while
+			// the lock is held, once we remove it there is room for the
+			// merged message.
+			select {
+			case pending := <-channel:
+				channel <- pending.merge(message)
+			default:
+				channel <- message
+			}
+		}
 	}
 }
 
+// CloseAll ends all subscriptions. Subscriptions that are created
+// afterwards have a closed channel.
 func (notifier *Notifier) CloseAll() {
-	for id := range notifier.channels {
-		notifier.Close(id)
+	notifier.lock.Lock()
+	defer notifier.lock.Unlock()
+	for id := range notifier.subscriptions {
+		notifier.unsubscribe(id)
+	}
+	notifier.closed = true
+}
+
+// unsubscribe must be called with the lock held.
+func (notifier *Notifier) unsubscribe(id string) {
+	subscription, ok := notifier.subscriptions[id]
+	if ok {
+		close(subscription.channel)
+		delete(notifier.subscriptions, id)
 	}
 }
@@ -0,0 +1,168 @@
+package notify
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestNotifier(t *testing.T) {
+	t.Run("should deliver message to every subscriber", func(t *testing.T) {
+		notifier := New()
+		defer notifier.CloseAll()
+		subscription1 := notifier.Subscribe(SubscriberInfo{})
+		subscription2 := notifier.Subscribe(SubscriberInfo{})
+		notifier.Notify(Message{Type: Reload})
+		for i, channel := range []<-chan Message{subscription1.Messages, subscription2.Messages} {
+			select {
+			case message := <-channel:
+				if message.Type != Reload {
+					t.Errorf("subscriber %d got message type %q but expected %q", i+1, message.Type, Reload)
+				}
+			default:
+				t.Errorf("subscriber %d did not get message", i+1)
+			}
+		}
+	})
+
+	t.Run("should not block when subscriber is not receiving", func(t *testing.T) {
+		notifier := New()
+		defer notifier.CloseAll()
+		notifier.Subscribe(SubscriberInfo{})
+		done := make(chan struct{})
+		go func() {
+			for i := 0; i < 10; i++ {
+				notifier.Notify(Message{Type: Reload})
+			}
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Notify() blocked on subscriber that is not receiving")
+		}
+	})
+
+	t.Run("should coalesce pending messages", func(t *testing.T) {
+		notifier := New()
+		defer notifier.CloseAll()
+		channel := notifier.Subscribe(SubscriberInfo{}).Messages
+		notifier.Notify(Message{Type: Reload})
+		notifier.Notify(Message{Type: Error, Error: &BuildError{Message: "oops"}})
+		message := <-channel
+		if message.Type != Error || message.Error.Message != "oops" {
+			t.Errorf("got message %+v but expected most recent message", message)
+		}
+		select {
+		case message := <-channel:
+			t.Errorf("got unexpected second message %+v", message)
+		default:
+		}
+	})
+
+	t.Run("should merge pending CSS messages", func(t *testing.T) {
+		notifier := New()
+		defer notifier.CloseAll()
+		channel := notifier.Subscribe(SubscriberInfo{}).Messages
+		notifier.Notify(Message{Type: CSS, Paths: []string{"/a.css"}})
+		notifier.Notify(Message{Type: CSS, Paths: []string{"/b.css", "/a.css"}})
+		message := <-channel
+		if message.Type != CSS || !reflect.DeepEqual(message.Paths, []string{"/a.css", "/b.css"}) {
+			t.Errorf("got message %+v but expected CSS message with both paths", message)
+		}
+	})
+
+	t.Run("should reload when CSS message follows a pending error", func(t *testing.T) {
+		notifier := New()
+		defer notifier.CloseAll()
+		channel := notifier.Subscribe(SubscriberInfo{}).Messages
+		notifier.Notify(Message{Type: Error, Error: &BuildError{Message: "oops"}})
+		notifier.Notify(Message{Type: CSS, Paths: []string{"/a.css"}})
+		if message := <-channel; message.Type != Reload {
+			t.Errorf("got message %+v but expected reload", message)
+		}
+	})
+
+	t.Run("should generate unique IDs", func(t *testing.T) {
+		notifier := New()
+		defer notifier.CloseAll()
+		subscription1 := notifier.Subscribe(SubscriberInfo{Page: "/index.html"})
+		subscription2 := notifier.Subscribe(SubscriberInfo{Page: "/index.html"})
+		if subscription1.ID == "" || subscription1.ID == subscription2.ID {
+			t.Errorf("got IDs %q and %q but expected unique IDs", subscription1.ID, subscription2.ID)
+		}
+	})
+
+	t.Run("should close channel on unsubscribe", func(t *testing.T) {
+		notifier := New()
+		subscription := notifier.Subscribe(SubscriberInfo{})
+		subscription.Unsubscribe()
+		if _, ok := <-subscription.Messages; ok {
+			t.Errorf("channel of closed subscriber is not closed")
+		}
+		// unsubscribing again and notifying after unsubscribing must not panic
+		subscription.Unsubscribe()
+		notifier.Notify(Message{Type: Reload})
+	})
+
+	t.Run("should list subscribers in order of subscription", func(t *testing.T) {
+		notifier := New()
+		defer notifier.CloseAll()
+		subscription1 := notifier.Subscribe(SubscriberInfo{Page: "/a.html"})
+		subscription2 := notifier.Subscribe(SubscriberInfo{Page: "/b.html"})
+		subscription3 := notifier.Subscribe(SubscriberInfo{Page: "/c.html"})
+		subscription2.Unsubscribe()
+		subscribers := notifier.Subscribers()
+		if len(subscribers) != 2 {
+			t.Fatalf("got %d subscribers but expected 2", len(subscribers))
+		}
+		if subscribers[0].ID != subscription1.ID || subscribers[0].Page != "/a.html" {
+			t.Errorf("got unexpected first subscriber %+v", subscribers[0])
+		}
+		if subscribers[1].ID != subscription3.ID || subscribers[1].Page != "/c.html" {
+			t.Errorf("got unexpected second subscriber %+v", subscribers[1])
+		}
+	})
+
+	t.Run("should return closed channel after CloseAll", func(t *testing.T) {
+		notifier := New()
+		notifier.CloseAll()
+		subscription := notifier.Subscribe(SubscriberInfo{})
+		if _, ok := <-subscription.Messages; ok {
+			t.Errorf("channel of subscription created after CloseAll is not closed")
+		}
+	})
+
+	t.Run("should be safe for concurrent use", func(t *testing.T) {
+		notifier := New()
+		wg := sync.WaitGroup{}
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				subscription := notifier.Subscribe(SubscriberInfo{Page: fmt.Sprintf("/%d.html", i)})
+				for j := 0; j < 10; j++ {
+					select {
+					case <-subscription.Messages:
+					default:
+					}
+					notifier.Subscribers()
+				}
+				subscription.Unsubscribe()
+			}(i)
+		}
+		for i := 0; i < 5; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 50; j++ {
+					notifier.Notify(Message{Type: Reload})
+				}
+			}()
+		}
+		wg.Wait()
+		notifier.CloseAll()
+	})
+}
@@ -0,0 +1,151 @@
+package redirects
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// FileName is the name of the file, at the root of the built site, that
+// contains redirect rules.
+const FileName = "_redirects"
+
+// Rule is a rule from a _redirects file. The format of the file is that
+// used by Netlify and Cloudflare Pages: each line contains the path to
+// match, the path or URL to send the request to, and optionally a status
+// code. Blank lines and lines starting with # are ignored. For example:
+//
+//	/old-post       /posts/new-post
+//	/blog/*         /posts/:splat    302
+//	/docs/:version  /documentation/:version/index.html  200
+//	/api/*          /404.html        404!
+type Rule struct {
+	// The path that the rule applies to. A segment of the form :name
+	// matches any single segment, and a trailing * matches the rest of
+	// the path.
+	From string
+	// Where to send the request. Placeholders from From, and :splat for
+	// what * matched, are replaced with what they matched.
+	To string
+	// A 3xx status redirects to To. Any other status serves the file at
+	// To with that status, without changing the URL.
+	Status int
+	// Whether the rule applies even if there is a file at From. Written
+	// as a ! after the status code.
+	Force bool
+}
+
+// Parse reads rules from r. Invalid lines are skipped, and reported in the
+// returned error along with the valid rules.
+func Parse(r io.Reader) ([]Rule, error) {
+	rules := []Rule{}
+	errs := []error{}
+	scanner := bufio.NewScanner(r)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		rule, err := parseRule(line)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("line %d: %w", lineNumber, err))
+			continue
+		}
+		rules = append(rules, rule)
+	}
+	if err := scanner.Err(); err != nil {
+		errs = append(errs, fmt.Errorf("failed to read: %w", err))
+	}
+	return rules, errors.Join(errs...)
+}
+
+func parseRule(line string) (Rule, error) {
+	fields := strings.Fields(line)
+	if len(fields) < 2 {
+		return Rule{}, errors.New("expected a path and a destination")
+	}
+	if len(fields) > 3 {
+		return Rule{}, fmt.Errorf("unexpected %q after status", strings.Join(fields[3:], " "))
+	}
+	rule := Rule{
+		From:   fields[0],
+		To:     fields[1],
+		Status: http.StatusMovedPermanently,
+	}
+	if !strings.HasPrefix(rule.From, "/") {
+		return Rule{}, fmt.Errorf("path %q does not start with /", rule.From)
+	}
+	if strings.Contains(strings.TrimSuffix(rule.From, "*"), "*") {
+		return Rule{}, fmt.Errorf("path %q has * somewhere other than at the end", rule.From)
+	}
+	if len(fields) == 3 {
+		statusField, force := strings.CutSuffix(fields[2], "!")
+		status, err := strconv.Atoi(statusField)
+		if err != nil || status < 200 || status > 599 {
+			return Rule{}, fmt.Errorf("invalid status %q", fields[2])
+		}
+		rule.Status = status
+		rule.Force = force
+	}
+	return rule, nil
+}
+
+// IsRedirect returns whether the rule redirects, as opposed to serving
+// another file in place of the requested one.
+func (rule Rule) IsRedirect() bool {
+	return rule.Status >= 300 && rule.Status <= 399
+}
+
+// Match returns where the rule sends a request for urlPath, and whether
+// the rule applies to urlPath at all. Trailing slashes are ignored.
+func (rule Rule) Match(urlPath string) (string, bool) {
+	fromSegments := splitPath(strings.TrimSuffix(rule.From, "*"))
+	splat := strings.HasSuffix(rule.From, "*")
+	pathSegments := splitPath(urlPath)
+
+	if len(pathSegments) < len(fromSegments) || (!splat && len(pathSegments) != len(fromSegments)) {
+		return "", false
+	}
+	placeholders := map[string]string{}
+	for i, fromSegment := range fromSegments {
+		if name, ok := strings.CutPrefix(fromSegment, ":"); ok && name != "" {
+			placeholders[name] = pathSegments[i]
+		} else if fromSegment != pathSegments[i] {
+			return "", false
+		}
+	}
+	if splat {
+		placeholders["splat"] = strings.Join(pathSegments[len(fromSegments):], "/")
+	}
+
+	to := rule.To
+	// Replace longer names first, so that :page is not replaced inside
+	// :pages.
+	for len(placeholders) > 0 {
+		longest := ""
+		for name := range placeholders {
+			if len(name) > len(longest) || (len(name) == len(longest) && name < longest) {
+				longest = name
+			}
+		}
+		to = strings.ReplaceAll(to, ":"+longest, placeholders[longest])
+		delete(placeholders, longest)
+	}
+	return to, true
+}
+
+// splitPath splits urlPath into its segments, ignoring leading and
+// trailing slashes.
+func splitPath(urlPath string) []string {
+	trimmed := strings.Trim(urlPath, "/")
+	if trimmed == "" {
+		return []string{}
+	}
+	return strings.Split(trimmed, "/")
+}
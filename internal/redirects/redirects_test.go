@@ -0,0 +1,83 @@
+package redirects
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	t.Run("should parse rules", func(t *testing.T) {
+		contents := strings.Join([]string{
+			"# comment",
+			"",
+			"/old-post   /posts/new-post",
+			"/blog/*     /posts/:splat    302",
+			"/api/*      /404.html        404!",
+		}, "\n")
+		rules, err := Parse(strings.NewReader(contents))
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		expected := []Rule{
+			{From: "/old-post", To: "/posts/new-post", Status: 301},
+			{From: "/blog/*", To: "/posts/:splat", Status: 302},
+			{From: "/api/*", To: "/404.html", Status: 404, Force: true},
+		}
+		if !reflect.DeepEqual(rules, expected) {
+			t.Errorf("got %+v but expected %+v", rules, expected)
+		}
+	})
+
+	t.Run("should skip and report invalid lines", func(t *testing.T) {
+		contents := strings.Join([]string{
+			"/only-path",
+			"/a /b",
+			"/c /d abc",
+			"no-slash /e",
+		}, "\n")
+		rules, err := Parse(strings.NewReader(contents))
+		expected := []Rule{{From: "/a", To: "/b", Status: 301}}
+		if !reflect.DeepEqual(rules, expected) {
+			t.Errorf("got %+v but expected %+v", rules, expected)
+		}
+		if err == nil {
+			t.Fatalf("did not get error when we should have")
+		}
+		for _, line := range []string{"line 1:", "line 3:", "line 4:"} {
+			if !strings.Contains(err.Error(), line) {
+				t.Errorf("error %q does not mention %q", err, line)
+			}
+		}
+	})
+}
+
+func TestMatch(t *testing.T) {
+	cases := []struct {
+		rule     Rule
+		urlPath  string
+		expected string
+		ok       bool
+	}{
+		{Rule{From: "/old", To: "/new"}, "/old", "/new", true},
+		{Rule{From: "/old", To: "/new"}, "/old/", "/new", true},
+		{Rule{From: "/old", To: "/new"}, "/older", "", false},
+		{Rule{From: "/blog/*", To: "/posts/:splat"}, "/blog/2024/trip", "/posts/2024/trip", true},
+		{Rule{From: "/blog/*", To: "/posts/:splat"}, "/other/2024", "", false},
+		{Rule{From: "/*", To: "/index.html"}, "/anything/at/all", "/index.html", true},
+		{Rule{From: "/docs/:version/:page", To: "/:version/:page.html"}, "/docs/v2/intro", "/v2/intro.html", true},
+		{Rule{From: "/docs/:version/:page", To: "/:version/:page.html"}, "/docs/v2", "", false},
+		{Rule{From: "/:page/:pages", To: "/:pages/:page"}, "/a/b", "/b/a", true},
+	}
+	for _, c := range cases {
+		t.Run("should match "+c.urlPath+" against "+c.rule.From, func(t *testing.T) {
+			to, ok := c.rule.Match(c.urlPath)
+			if ok != c.ok {
+				t.Fatalf("got %t but expected %t", ok, c.ok)
+			}
+			if to != c.expected {
+				t.Errorf("got %q but expected %q", to, c.expected)
+			}
+		})
+	}
+}
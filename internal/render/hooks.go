@@ -0,0 +1,280 @@
+package render
+
+import (
+	"bytes"
+	"fmt"
+	"text/template"
+
+	"github.com/adamkpickering/jenny/internal/content"
+	"github.com/adamkpickering/jenny/internal/images"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/util"
+)
+
+const (
+	linkHook      = "link.gotmpl"
+	imageHook     = "image.gotmpl"
+	headingHook   = "heading.gotmpl"
+	codeBlockHook = "codeblock.gotmpl"
+)
+
+// LinkContext is the data passed to the link render hook.
+type LinkContext struct {
+	// The page that contains the link.
+	Page *content.ContentFile
+	// The URL the link points to, as written in the markdown.
+	Destination string
+	// The title of the link, if any.
+	Title string
+	// The rendered HTML of the link text.
+	Text string
+	// The link text with all markup removed.
+	PlainText string
+}
+
+// ImageContext is the data passed to the image render hook.
+type ImageContext struct {
+	// The page that contains the image.
+	Page *content.ContentFile
+	// The URL of the image, as written in the markdown.
+	Destination string
+	// The title of the image, if any.
+	Title string
+	// The alt text of the image.
+	Text string
+	// The image in several widths, or nil if responsive images are not
+	// enabled or Destination is not an image that can be processed.
+	Image *images.Responsive
+}
+
+// HeadingContext is the data passed to the heading render hook.
+type HeadingContext struct {
+	// The page that contains the heading.
+	Page *content.ContentFile
+	// The level of the heading, from 1 to 6.
+	Level int
+	// The ID of the heading, generated from its text.
+	Anchor string
+	// The rendered HTML of the heading text.
+	Text string
+	// The heading text with all markup removed.
+	PlainText string
+}
+
+// CodeBlockContext is the data passed to the code block render hook.
+type CodeBlockContext struct {
+	// The page that contains the code block.
+	Page *content.ContentFile
+	// The language given after the opening code fence, if any.
+	Language string
+	// The unescaped contents of the code block.
+	Code string
+}
+
+// hookRenderer is a goldmark NodeRenderer that renders nodes using
+// user-supplied templates. It only registers itself for the kinds of
+// nodes that have a template.
+type hookRenderer struct {
+	templates *template.Template
+	// Used to find the responsive versions of images. If it is nil,
+	// images are rendered as they are.
+	resolveImage ImageResolver
+	// Renders images as goldmark does when there is no render hook for
+	// them.
+	defaultImage renderer.NodeRendererFunc
+	// Used to render the children of nodes, such as the text of links.
+	renderer renderer.Renderer
+}
+
+func (hooks *hookRenderer) has(name string) bool {
+	return hooks.templates != nil && hooks.templates.Lookup(name) != nil
+}
+
+func (hooks *hookRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	if hooks.has(linkHook) {
+		reg.Register(ast.KindLink, hooks.renderLink)
+	}
+	if hooks.has(imageHook) || hooks.resolveImage != nil {
+		reg.Register(ast.KindImage, hooks.renderImage)
+	}
+	if hooks.has(headingHook) {
+		reg.Register(ast.KindHeading, hooks.renderHeading)
+	}
+	if hooks.has(codeBlockHook) {
+		reg.Register(ast.KindCodeBlock, hooks.renderCodeBlock)
+		reg.Register(ast.KindFencedCodeBlock, hooks.renderCodeBlock)
+	}
+}
+
+func (hooks *hookRenderer) renderLink(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+	n := node.(*ast.Link)
+	renderedText, err := hooks.renderChildren(source, n)
+	if err != nil {
+		return ast.WalkStop, err
+	}
+	linkContext := LinkContext{
+		Page:        pageOf(n),
+		Destination: string(n.Destination),
+		Title:       string(n.Title),
+		Text:        renderedText,
+		PlainText:   plainText(source, n),
+	}
+	if err := hooks.execute(w, linkHook, linkContext); err != nil {
+		return ast.WalkStop, err
+	}
+	return ast.WalkSkipChildren, nil
+}
+
+func (hooks *hookRenderer) renderImage(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+	n := node.(*ast.Image)
+	imageContext := ImageContext{
+		Page:        pageOf(n),
+		Destination: string(n.Destination),
+		Title:       string(n.Title),
+		Text:        plainText(source, n),
+	}
+	if hooks.resolveImage != nil {
+		responsive, err := hooks.resolveImage(imageContext.Page, imageContext.Destination)
+		if err != nil {
+			return ast.WalkStop, fmt.Errorf("failed to process image %s: %w", imageContext.Destination, err)
+		}
+		imageContext.Image = responsive
+	}
+	if !hooks.has(imageHook) {
+		if imageContext.Image == nil {
+			return hooks.defaultImage(w, source, node, entering)
+		}
+		writeResponsiveImage(w, imageContext)
+		return ast.WalkSkipChildren, nil
+	}
+	if err := hooks.execute(w, imageHook, imageContext); err != nil {
+		return ast.WalkStop, err
+	}
+	return ast.WalkSkipChildren, nil
+}
+
+// writeResponsiveImage writes an img element for the responsive image in
+// imageContext. It is used when there is no image render hook, so that
+// responsive images work without one.
+func writeResponsiveImage(w util.BufWriter, imageContext ImageContext) {
+	responsive := imageContext.Image
+	fmt.Fprintf(w, `<img src="%s" srcset="%s" sizes="%s" width="%d" height="%d" alt="%s"`,
+		escapeAttribute(responsive.URL), escapeAttribute(responsive.Srcset), escapeAttribute(responsive.Sizes),
+		responsive.Width, responsive.Height, escapeAttribute(imageContext.Text))
+	if imageContext.Title != "" {
+		fmt.Fprintf(w, ` title="%s"`, escapeAttribute(imageContext.Title))
+	}
+	w.WriteString(">")
+}
+
+func escapeAttribute(value string) string {
+	return string(util.EscapeHTML([]byte(value)))
+}
+
+// nodeRendererFuncs collects the functions that a goldmark NodeRenderer
+// registers.
+type nodeRendererFuncs map[ast.NodeKind]renderer.NodeRendererFunc
+
+func (funcs nodeRendererFuncs) Register(kind ast.NodeKind, fn renderer.NodeRendererFunc) {
+	funcs[kind] = fn
+}
+
+func (hooks *hookRenderer) renderHeading(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+	n := node.(*ast.Heading)
+	renderedText, err := hooks.renderChildren(source, n)
+	if err != nil {
+		return ast.WalkStop, err
+	}
+	anchor := ""
+	if id, ok := n.AttributeString("id"); ok {
+		if idBytes, ok := id.([]byte); ok {
+			anchor = string(idBytes)
+		}
+	}
+	headingContext := HeadingContext{
+		Page:      pageOf(n),
+		Level:     n.Level,
+		Anchor:    anchor,
+		Text:      renderedText,
+		PlainText: plainText(source, n),
+	}
+	if err := hooks.execute(w, headingHook, headingContext); err != nil {
+		return ast.WalkStop, err
+	}
+	return ast.WalkSkipChildren, nil
+}
+
+func (hooks *hookRenderer) renderCodeBlock(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+	codeBlockContext := CodeBlockContext{
+		Page: pageOf(node),
+	}
+	if n, ok := node.(*ast.FencedCodeBlock); ok {
+		codeBlockContext.Language = string(n.Language(source))
+	}
+	code := &bytes.Buffer{}
+	lines := node.Lines()
+	for i := 0; i < lines.Len(); i++ {
+		line := lines.At(i)
+		code.Write(line.Value(source))
+	}
+	codeBlockContext.Code = code.String()
+	if err := hooks.execute(w, codeBlockHook, codeBlockContext); err != nil {
+		return ast.WalkStop, err
+	}
+	return ast.WalkSkipChildren, nil
+}
+
+// renderChildren renders the children of node to HTML using the
+// full renderer, so that nested nodes are rendered as they would be
+// anywhere else.
+func (hooks *hookRenderer) renderChildren(source []byte, node ast.Node) (string, error) {
+	rendered := &bytes.Buffer{}
+	for child := node.FirstChild(); child != nil; child = child.NextSibling() {
+		if err := hooks.renderer.Render(rendered, source, child); err != nil {
+			return "", err
+		}
+	}
+	return rendered.String(), nil
+}
+
+// plainText returns the text of the descendants of node with all markup
+// removed.
+func plainText(source []byte, node ast.Node) string {
+	text := &bytes.Buffer{}
+	_ = ast.Walk(node, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch n := n.(type) {
+		case *ast.Text:
+			text.Write(n.Value(source))
+			if n.SoftLineBreak() || n.HardLineBreak() {
+				text.WriteByte(' ')
+			}
+		case *ast.String:
+			text.Write(n.Value)
+		}
+		return ast.WalkContinue, nil
+	})
+	return text.String()
+}
+
+func (hooks *hookRenderer) execute(w util.BufWriter, name string, data any) error {
+	if err := hooks.templates.ExecuteTemplate(w, name, data); err != nil {
+		return fmt.Errorf("failed to execute render hook %s: %w", name, err)
+	}
+	return nil
+}
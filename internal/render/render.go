@@ -0,0 +1,133 @@
+package render
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"text/template"
+
+	"github.com/adamkpickering/jenny/internal/content"
+	"github.com/adamkpickering/jenny/internal/ignore"
+	"github.com/adamkpickering/jenny/internal/images"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/renderer/html"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// HooksDir is the name of the subdirectory of the templates directory
+// that contains render hook templates.
+const HooksDir = "_render"
+
+// pageMetaKey is the key under which the page being converted is stored
+// in the metadata of the goldmark document.
+const pageMetaKey = "Page"
+
+// ImageResolver returns the responsive versions of the image at
+// destination, which is the destination of an image in the markdown of
+// page, or nil if destination is not an image that can be processed.
+type ImageResolver func(page *content.ContentFile, destination string) (*images.Responsive, error)
+
+// Renderer converts the markdown content of content files to HTML.
+// Rendering of links, images, headings and code blocks may be overridden
+// by render hook templates.
+type Renderer struct {
+	markdown goldmark.Markdown
+}
+
+// New returns a Renderer that uses the render hook templates found in
+// the _render subdirectory of templatesDir, other than those that ignored
+// ignores. It is not an error for this subdirectory not to exist. If
+// resolveImage is not nil, images are rendered with the srcset, sizes,
+// width and height attributes of the images that it returns.
+func New(templatesDir string, ignored *ignore.Matcher, resolveImage ImageResolver) (*Renderer, error) {
+	hooks, err := parseHooks(filepath.Join(templatesDir, HooksDir), ignored)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse render hooks: %w", err)
+	}
+	hooks.resolveImage = resolveImage
+	defaultFuncs := nodeRendererFuncs{}
+	html.NewRenderer().RegisterFuncs(defaultFuncs)
+	hooks.defaultImage = defaultFuncs[ast.KindImage]
+
+	parserOptions := []parser.Option{}
+	if hooks.has(headingHook) {
+		// Heading hooks are mostly useful for adding anchor links, so
+		// make sure every heading has an ID to link to.
+		parserOptions = append(parserOptions, parser.WithAutoHeadingID())
+	}
+	parserOptions = append(parserOptions, parser.WithASTTransformers(
+		util.Prioritized(pageTransformer{}, 100),
+	))
+	markdown := goldmark.New(
+		goldmark.WithParserOptions(parserOptions...),
+		goldmark.WithRendererOptions(
+			renderer.WithNodeRenderers(util.Prioritized(hooks, 100)),
+		),
+	)
+	hooks.renderer = markdown.Renderer()
+
+	r := &Renderer{
+		markdown: markdown,
+	}
+	return r, nil
+}
+
+// Convert converts markdown that belongs to contentFile to HTML.
+func (r *Renderer) Convert(contentFile *content.ContentFile, markdown string) (string, error) {
+	source := []byte(markdown)
+	parserContext := parser.NewContext()
+	parserContext.Set(pageContextKey, contentFile)
+	document := r.markdown.Parser().Parse(text.NewReader(source), parser.WithContext(parserContext))
+
+	builtContent := &bytes.Buffer{}
+	if err := r.markdown.Renderer().Render(builtContent, source, document); err != nil {
+		return "", err
+	}
+	return builtContent.String(), nil
+}
+
+func parseHooks(hooksDir string, ignored *ignore.Matcher) (*hookRenderer, error) {
+	hooks := &hookRenderer{}
+	if _, err := os.Stat(hooksDir); errors.Is(err, fs.ErrNotExist) {
+		return hooks, nil
+	}
+
+	hooksGlob := filepath.Join(hooksDir, "*.gotmpl")
+	matches, err := filepath.Glob(hooksGlob)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list templates in %s: %w", hooksDir, err)
+	}
+	hookPaths := make([]string, 0, len(matches))
+	for _, match := range matches {
+		if !ignored.Ignored(match, false) {
+			hookPaths = append(hookPaths, match)
+		}
+	}
+	if len(hookPaths) == 0 {
+		return hooks, nil
+	}
+
+	templates, err := template.ParseFiles(hookPaths...)
+	if err != nil {
+		return nil, err
+	}
+	hooks.templates = templates
+	return hooks, nil
+}
+
+// pageOf returns the content file that node belongs to.
+func pageOf(node ast.Node) *content.ContentFile {
+	document := node.OwnerDocument()
+	if document == nil {
+		return nil
+	}
+	contentFile, _ := document.Meta()[pageMetaKey].(*content.ContentFile)
+	return contentFile
+}
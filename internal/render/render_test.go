@@ -0,0 +1,187 @@
+package render
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/adamkpickering/jenny/internal/content"
+	"github.com/adamkpickering/jenny/internal/ignore"
+	"github.com/adamkpickering/jenny/internal/images"
+)
+
+func writeHook(t *testing.T, templatesDir, name, contents string) {
+	t.Helper()
+	hooksDir := filepath.Join(templatesDir, HooksDir)
+	if err := os.MkdirAll(hooksDir, 0o755); err != nil {
+		t.Fatalf("failed to create hooks dir: %s", err)
+	}
+	if err := os.WriteFile(filepath.Join(hooksDir, name), []byte(contents), 0o644); err != nil {
+		t.Fatalf("failed to write hook %s: %s", name, err)
+	}
+}
+
+func convert(t *testing.T, templatesDir, rawContent string) string {
+	t.Helper()
+	return convertWithImages(t, templatesDir, nil, rawContent)
+}
+
+func convertWithImages(t *testing.T, templatesDir string, resolveImage ImageResolver, rawContent string) string {
+	t.Helper()
+	renderer, err := New(templatesDir, nil, resolveImage)
+	if err != nil {
+		t.Fatalf("unexpected error in New(): %s", err)
+	}
+	contentFile := &content.ContentFile{
+		Path:       "/post.html",
+		RawContent: rawContent,
+	}
+	builtContent, err := renderer.Convert(contentFile, contentFile.RawContent)
+	if err != nil {
+		t.Fatalf("unexpected error in Convert(): %s", err)
+	}
+	return builtContent
+}
+
+func TestConvert(t *testing.T) {
+	t.Run("should render normally when there are no hooks", func(t *testing.T) {
+		templatesDir := t.TempDir()
+		builtContent := convert(t, templatesDir, "# Title\n\n[a *link*](https://example.com)")
+		expected := "<h1>Title</h1>\n<p><a href=\"https://example.com\">a <em>link</em></a></p>\n"
+		if builtContent != expected {
+			t.Errorf("got %q but expected %q", builtContent, expected)
+		}
+	})
+
+	t.Run("should use link hook", func(t *testing.T) {
+		templatesDir := t.TempDir()
+		writeHook(t, templatesDir, "link.gotmpl", `<a href="{{ .Destination }}" target="_blank">{{ .Text }}</a>{{ .Page.Path }}`)
+		builtContent := convert(t, templatesDir, "[a *link*](https://example.com)")
+		expected := "<p><a href=\"https://example.com\" target=\"_blank\">a <em>link</em></a>/post.html</p>\n"
+		if builtContent != expected {
+			t.Errorf("got %q but expected %q", builtContent, expected)
+		}
+	})
+
+	t.Run("should use image hook", func(t *testing.T) {
+		templatesDir := t.TempDir()
+		writeHook(t, templatesDir, "image.gotmpl", `<figure><img src="{{ .Destination }}" alt="{{ .Text }}" loading="lazy"></figure>`)
+		builtContent := convert(t, templatesDir, "![a photo](/photo.jpg)")
+		expected := "<p><figure><img src=\"/photo.jpg\" alt=\"a photo\" loading=\"lazy\"></figure></p>\n"
+		if builtContent != expected {
+			t.Errorf("got %q but expected %q", builtContent, expected)
+		}
+	})
+
+	t.Run("should use heading hook with generated anchor", func(t *testing.T) {
+		templatesDir := t.TempDir()
+		writeHook(t, templatesDir, "heading.gotmpl", `<h{{ .Level }} id="{{ .Anchor }}"><a href="#{{ .Anchor }}">{{ .Text }}</a></h{{ .Level }}>`)
+		builtContent := convert(t, templatesDir, "## Here is a `subheading`")
+		expected := "<h2 id=\"here-is-a-subheading\"><a href=\"#here-is-a-subheading\">Here is a <code>subheading</code></a></h2>"
+		if builtContent != expected {
+			t.Errorf("got %q but expected %q", builtContent, expected)
+		}
+	})
+
+	t.Run("should use code block hook", func(t *testing.T) {
+		templatesDir := t.TempDir()
+		writeHook(t, templatesDir, "codeblock.gotmpl", `<pre data-lang="{{ .Language }}">{{ html .Code }}</pre>`)
+		builtContent := convert(t, templatesDir, "```go\nif a < b {}\n```")
+		expected := "<pre data-lang=\"go\">if a &lt; b {}\n</pre>"
+		if builtContent != expected {
+			t.Errorf("got %q but expected %q", builtContent, expected)
+		}
+	})
+
+	t.Run("should resolve relative references to page resources", func(t *testing.T) {
+		renderer, err := New(t.TempDir(), nil, nil)
+		if err != nil {
+			t.Fatalf("unexpected error in New(): %s", err)
+		}
+		contentFile := &content.ContentFile{
+			Path: "/posts/my-trip/index.html",
+			Resources: content.Resources{
+				{Name: "photo 1.jpg", Path: "/posts/my-trip/photo 1.jpg"},
+				{Name: "maps/route.pdf", Path: "/posts/my-trip/maps/route.pdf"},
+			},
+		}
+		markdown := "![a photo](photo%201.jpg) [route](./maps/route.pdf#page=2) [other](other.html)"
+		builtContent, err := renderer.Convert(contentFile, markdown)
+		if err != nil {
+			t.Fatalf("unexpected error in Convert(): %s", err)
+		}
+		expected := "<p><img src=\"/posts/my-trip/photo%201.jpg\" alt=\"a photo\"> " +
+			"<a href=\"/posts/my-trip/maps/route.pdf#page=2\">route</a> " +
+			"<a href=\"other.html\">other</a></p>\n"
+		if builtContent != expected {
+			t.Errorf("got %q but expected %q", builtContent, expected)
+		}
+	})
+
+	resolveImage := func(page *content.ContentFile, destination string) (*images.Responsive, error) {
+		if destination != "photo.jpg" {
+			return nil, nil
+		}
+		small := &images.Image{URL: "/photo_small.jpg", Width: 400, Height: 300}
+		large := &images.Image{URL: "/photo_large.jpg", Width: 800, Height: 600}
+		return &images.Responsive{
+			Image:  large,
+			Images: []*images.Image{small, large},
+			Sizes:  "100vw",
+			Srcset: "/photo_small.jpg 400w, /photo_large.jpg 800w",
+		}, nil
+	}
+
+	t.Run("should render responsive images", func(t *testing.T) {
+		builtContent := convertWithImages(t, t.TempDir(), resolveImage, `![a "photo"](photo.jpg "title") ![other](other.gif)`)
+		expected := "<p><img src=\"/photo_large.jpg\" srcset=\"/photo_small.jpg 400w, /photo_large.jpg 800w\" sizes=\"100vw\" " +
+			"width=\"800\" height=\"600\" alt=\"a &quot;photo&quot;\" title=\"title\"> <img src=\"other.gif\" alt=\"other\"></p>\n"
+		if builtContent != expected {
+			t.Errorf("got %q but expected %q", builtContent, expected)
+		}
+	})
+
+	t.Run("should pass responsive images to image hook", func(t *testing.T) {
+		templatesDir := t.TempDir()
+		writeHook(t, templatesDir, "image.gotmpl", `{{ with .Image }}{{ .Srcset }} {{ .Width }}x{{ .Height }}{{ else }}{{ $.Destination }}{{ end }}`)
+		builtContent := convertWithImages(t, templatesDir, resolveImage, "![a](photo.jpg) ![b](other.gif)")
+		expected := "<p>/photo_small.jpg 400w, /photo_large.jpg 800w 800x600 other.gif</p>\n"
+		if builtContent != expected {
+			t.Errorf("got %q but expected %q", builtContent, expected)
+		}
+	})
+
+	t.Run("should not use ignored hooks", func(t *testing.T) {
+		templatesDir := t.TempDir()
+		writeHook(t, templatesDir, "link.gotmpl", `hooked`)
+		ignored, err := ignore.New(templatesDir, []string{"_render/link.gotmpl"})
+		if err != nil {
+			t.Fatalf("unexpected error in ignore.New(): %s", err)
+		}
+		renderer, err := New(templatesDir, ignored, nil)
+		if err != nil {
+			t.Fatalf("unexpected error in New(): %s", err)
+		}
+		contentFile := &content.ContentFile{RawContent: "[link](/)"}
+		builtContent, err := renderer.Convert(contentFile, contentFile.RawContent)
+		if err != nil {
+			t.Fatalf("unexpected error in Convert(): %s", err)
+		}
+		if expected := "<p><a href=\"/\">link</a></p>\n"; builtContent != expected {
+			t.Errorf("got %q but expected %q", builtContent, expected)
+		}
+	})
+
+	t.Run("should return error when hook fails", func(t *testing.T) {
+		templatesDir := t.TempDir()
+		writeHook(t, templatesDir, "link.gotmpl", `{{ .DoesNotExist }}`)
+		renderer, err := New(templatesDir, nil, nil)
+		if err != nil {
+			t.Fatalf("unexpected error in New(): %s", err)
+		}
+		contentFile := &content.ContentFile{RawContent: "[link](/)"}
+		if _, err := renderer.Convert(contentFile, contentFile.RawContent); err == nil {
+			t.Errorf("did not get error from Convert() when we should have")
+		}
+	})
+}
@@ -0,0 +1,63 @@
+package render
+
+import (
+	"net/url"
+	"path"
+
+	"github.com/adamkpickering/jenny/internal/content"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+)
+
+// pageContextKey is used to pass the page being converted to pageTransformer.
+var pageContextKey = parser.NewContextKey()
+
+// pageTransformer is a goldmark ASTTransformer that stores the page being
+// converted in the document metadata, so that render hooks can access it.
+// It also rewrites relative links to resources of the page so that they
+// use the absolute path of the resource.
+type pageTransformer struct{}
+
+func (pageTransformer) Transform(document *ast.Document, reader text.Reader, pc parser.Context) {
+	contentFile, ok := pc.Get(pageContextKey).(*content.ContentFile)
+	if !ok || contentFile == nil {
+		return
+	}
+	document.AddMeta(pageMetaKey, contentFile)
+
+	if len(contentFile.Resources) == 0 {
+		return
+	}
+	_ = ast.Walk(document, func(node ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch n := node.(type) {
+		case *ast.Link:
+			n.Destination = resolveResource(contentFile.Resources, n.Destination)
+		case *ast.Image:
+			n.Destination = resolveResource(contentFile.Resources, n.Destination)
+		}
+		return ast.WalkContinue, nil
+	})
+}
+
+// resolveResource returns the absolute path of the resource that destination
+// refers to. If destination does not refer to a resource, it is returned
+// unchanged.
+func resolveResource(resources content.Resources, destination []byte) []byte {
+	destinationUrl, err := url.Parse(string(destination))
+	if err != nil || destinationUrl.IsAbs() || destinationUrl.Host != "" {
+		return destination
+	}
+	if destinationUrl.Path == "" || path.IsAbs(destinationUrl.Path) {
+		return destination
+	}
+	resource := resources.Get(path.Clean(destinationUrl.Path))
+	if resource == nil {
+		return destination
+	}
+	destinationUrl.Path = resource.Path
+	return []byte(destinationUrl.String())
+}
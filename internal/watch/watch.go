@@ -0,0 +1,346 @@
+package watch
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// Watcher watches directory trees and individual files for changes. Changes
+// are collected until none have happened for the quiet window, and are then
+// reported together. Directories that are created in a watched tree are
+// watched as well. It is safe to add and remove watches at any time.
+type Watcher struct {
+	// Receives the paths that changed, sorted and without duplicates, each
+	// time the quiet window passes after a change. It is closed when the
+	// Watcher is closed.
+	Changes <-chan []string
+	// Receives errors from the underlying watcher. It is closed when the
+	// Watcher is closed.
+	Errors <-chan error
+
+	changes     chan []string
+	errors      chan error
+	watcher     *fsnotify.Watcher
+	quietWindow time.Duration
+	done        chan struct{}
+
+	lock sync.Mutex
+	// The directories that are being watched as part of a tree.
+	dirs map[string]bool
+	// The paths that are being watched individually.
+	files map[string]bool
+	// The number of entries in files that each directory is watched for.
+	fileDirs map[string]int
+	// Returns whether a path in a watched tree is ignored. It may be nil.
+	ignored func(filePath string, isDir bool) bool
+}
+
+// New returns a Watcher that reports changes once none have happened for
+// quietWindow.
+func New(quietWindow time.Duration) (*Watcher, error) {
+	fsWatcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create watcher: %w", err)
+	}
+	changes := make(chan []string)
+	errs := make(chan error)
+	watcher := &Watcher{
+		Changes:     changes,
+		Errors:      errs,
+		changes:     changes,
+		errors:      errs,
+		watcher:     fsWatcher,
+		quietWindow: quietWindow,
+		done:        make(chan struct{}),
+		dirs:        map[string]bool{},
+		files:       map[string]bool{},
+		fileDirs:    map[string]int{},
+	}
+	return watcher, nil
+}
+
+// AddRecursive watches dir and all directories under it.
+func (watcher *Watcher) AddRecursive(dir string) error {
+	watcher.lock.Lock()
+	defer watcher.lock.Unlock()
+	_, err := watcher.addRecursive(filepath.Clean(dir))
+	return err
+}
+
+// RemoveRecursive stops watching dir and all directories under it.
+func (watcher *Watcher) RemoveRecursive(dir string) {
+	watcher.lock.Lock()
+	defer watcher.lock.Unlock()
+	watcher.removeRecursive(filepath.Clean(dir))
+}
+
+// AddFile watches the file (or directory) at filePath, which does not need
+// to exist. Only changes to filePath itself are reported: the contents of
+// a directory are not watched.
+func (watcher *Watcher) AddFile(filePath string) error {
+	watcher.lock.Lock()
+	defer watcher.lock.Unlock()
+	filePath = filepath.Clean(filePath)
+	if watcher.files[filePath] {
+		return nil
+	}
+	// Files are watched through the directory they are in, since editors
+	// often replace files rather than writing to them.
+	dir := filepath.Dir(filePath)
+	if watcher.fileDirs[dir] == 0 && !watcher.dirs[dir] {
+		if err := watcher.watcher.Add(dir); err != nil {
+			return fmt.Errorf("failed to watch %s: %w", dir, err)
+		}
+	}
+	watcher.fileDirs[dir]++
+	watcher.files[filePath] = true
+	return nil
+}
+
+// RemoveFile stops watching filePath.
+func (watcher *Watcher) RemoveFile(filePath string) {
+	watcher.lock.Lock()
+	defer watcher.lock.Unlock()
+	filePath = filepath.Clean(filePath)
+	if !watcher.files[filePath] {
+		return
+	}
+	delete(watcher.files, filePath)
+	dir := filepath.Dir(filePath)
+	watcher.fileDirs[dir]--
+	if watcher.fileDirs[dir] == 0 {
+		delete(watcher.fileDirs, dir)
+		if !watcher.dirs[dir] {
+			_ = watcher.watcher.Remove(dir)
+		}
+	}
+}
+
+// SetIgnored makes the watcher ignore the files and directories in watched
+// trees for which ignored returns true: changes to them are not reported,
+// and ignored directories are not watched. Directories that are already
+// watched stay watched until they are removed.
+func (watcher *Watcher) SetIgnored(ignored func(filePath string, isDir bool) bool) {
+	watcher.lock.Lock()
+	defer watcher.lock.Unlock()
+	watcher.ignored = ignored
+}
+
+// Start starts reporting changes.
+func (watcher *Watcher) Start() {
+	go watcher.run()
+}
+
+// Close stops watching. It must only be called once.
+func (watcher *Watcher) Close() error {
+	close(watcher.done)
+	return watcher.watcher.Close()
+}
+
+func (watcher *Watcher) run() {
+	defer close(watcher.changes)
+	defer close(watcher.errors)
+
+	pending := map[string]bool{}
+	var ready []string
+	timer := time.NewTimer(watcher.quietWindow)
+	timer.Stop()
+
+	for {
+		// Only try to send when there is something to send.
+		var changes chan []string
+		if len(ready) > 0 {
+			changes = watcher.changes
+		}
+
+		select {
+		case <-watcher.done:
+			timer.Stop()
+			return
+		case changes <- ready:
+			ready = nil
+		case <-timer.C:
+			for changedPath := range pending {
+				if !slices.Contains(ready, changedPath) {
+					ready = append(ready, changedPath)
+				}
+			}
+			slices.Sort(ready)
+			clear(pending)
+		case event, ok := <-watcher.watcher.Events:
+			if !ok {
+				return
+			}
+			watcher.lock.Lock()
+			changedPaths, err := watcher.handleEvent(event)
+			watcher.lock.Unlock()
+			if len(changedPaths) > 0 {
+				for _, changedPath := range changedPaths {
+					pending[changedPath] = true
+				}
+				timer.Reset(watcher.quietWindow)
+			}
+			if err == nil {
+				continue
+			}
+			// Sending may block, so it is done without the lock held,
+			// and here rather than in another goroutine so that nothing
+			// sends after the channels are closed.
+			select {
+			case watcher.errors <- err:
+			case <-watcher.done:
+				return
+			}
+		case err, ok := <-watcher.watcher.Errors:
+			if !ok {
+				return
+			}
+			select {
+			case watcher.errors <- err:
+			case <-watcher.done:
+				return
+			}
+		}
+	}
+}
+
+// handleEvent updates the set of watched directories based on event, and
+// returns the paths that it changed, along with any error from watching
+// new directories. No paths are returned for events that should not cause
+// a rebuild. It must be called with the lock held.
+func (watcher *Watcher) handleEvent(event fsnotify.Event) ([]string, error) {
+	// Events for files in the directory "." are named like "./name".
+	event.Name = filepath.Clean(event.Name)
+	if IsEditorFile(event.Name) {
+		return nil, nil
+	}
+	if event.Has(fsnotify.Remove) || event.Has(fsnotify.Rename) {
+		watcher.removeRecursive(event.Name)
+	}
+	// The event is for a file in a directory that is only watched for
+	// the sake of individual files.
+	if !watcher.dirs[filepath.Dir(event.Name)] {
+		if watcher.files[event.Name] && event.Op != fsnotify.Chmod {
+			return []string{event.Name}, nil
+		}
+		return nil, nil
+	}
+	// Only the permissions changed, which happens for example when a
+	// file is indexed or backed up.
+	if event.Op == fsnotify.Chmod {
+		return nil, nil
+	}
+	if watcher.ignored != nil {
+		fileInfo, err := os.Lstat(event.Name)
+		if watcher.ignored(event.Name, err == nil && fileInfo.IsDir()) {
+			return nil, nil
+		}
+	}
+
+	if event.Has(fsnotify.Create) {
+		fileInfo, err := os.Stat(event.Name)
+		if err == nil && fileInfo.IsDir() {
+			// Files may have been created in the directory before it
+			// was watched, so report everything in it as changed.
+			changedPaths, err := watcher.addRecursive(event.Name)
+			// The directory may have been removed again already.
+			if errors.Is(err, fs.ErrNotExist) {
+				err = nil
+			}
+			return append(changedPaths, event.Name), err
+		}
+	}
+
+	return []string{event.Name}, nil
+}
+
+// addRecursive watches dir and all directories under it, and returns the
+// paths of everything under dir. It must be called with the lock held.
+func (watcher *Watcher) addRecursive(dir string) ([]string, error) {
+	paths := []string{}
+	err := filepath.WalkDir(dir, func(walkPath string, dirEntry fs.DirEntry, err error) error {
+		if err != nil {
+			// Something under dir may have been removed again
+			// already.
+			if errors.Is(err, fs.ErrNotExist) && walkPath != dir {
+				return nil
+			}
+			return err
+		}
+		if walkPath != dir && watcher.ignored != nil && watcher.ignored(walkPath, dirEntry.IsDir()) {
+			if dirEntry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if walkPath != dir && !IsEditorFile(walkPath) {
+			paths = append(paths, walkPath)
+		}
+		if !dirEntry.IsDir() || watcher.dirs[walkPath] {
+			return nil
+		}
+		if watcher.fileDirs[walkPath] == 0 {
+			if err := watcher.watcher.Add(walkPath); err != nil {
+				return fmt.Errorf("failed to watch %s: %w", walkPath, err)
+			}
+		}
+		watcher.dirs[walkPath] = true
+		return nil
+	})
+	return paths, err
+}
+
+// removeRecursive stops watching dir and all directories under it. It does
+// nothing if dir is not a watched directory. It must be called with the
+// lock held.
+func (watcher *Watcher) removeRecursive(dir string) {
+	prefix := dir + string(filepath.Separator)
+	for watchedDir := range watcher.dirs {
+		if watchedDir == dir || strings.HasPrefix(watchedDir, prefix) {
+			delete(watcher.dirs, watchedDir)
+			if watcher.fileDirs[watchedDir] > 0 {
+				continue
+			}
+			// The watch is removed automatically if the directory was
+			// deleted, so there may be nothing to remove.
+			_ = watcher.watcher.Remove(watchedDir)
+		}
+	}
+}
+
+// IsEditorFile returns whether the file at filePath is a temporary file
+// that an editor creates while a file is being edited, such as a swap or
+// backup file.
+func IsEditorFile(filePath string) bool {
+	name := filepath.Base(filePath)
+	switch {
+	case strings.HasSuffix(name, "~"):
+		// emacs and many others: backup files
+		return true
+	case strings.HasPrefix(name, ".#"):
+		// emacs: lock files
+		return true
+	case strings.HasPrefix(name, "#") && strings.HasSuffix(name, "#"):
+		// emacs: auto-save files
+		return true
+	case strings.HasPrefix(name, ".") && (strings.HasSuffix(name, ".swp") || strings.HasSuffix(name, ".swo") || strings.HasSuffix(name, ".swx")):
+		// vim: swap files
+		return true
+	case name == "4913":
+		// vim: checks whether it can create files in the directory
+		return true
+	case strings.HasSuffix(name, "___jb_tmp___") || strings.HasSuffix(name, "___jb_old___"):
+		// JetBrains IDEs: safe write
+		return true
+	}
+	return false
+}
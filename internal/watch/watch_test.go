@@ -0,0 +1,217 @@
+package watch
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+const testQuietWindow = 50 * time.Millisecond
+
+func newTestWatcher(t *testing.T, dir string) *Watcher {
+	t.Helper()
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatalf("failed to create directory: %s", err)
+	}
+	watcher, err := New(testQuietWindow)
+	if err != nil {
+		t.Fatalf("unexpected error in New(): %s", err)
+	}
+	t.Cleanup(func() { watcher.Close() })
+	if err := watcher.AddRecursive(dir); err != nil {
+		t.Fatalf("unexpected error in AddRecursive(): %s", err)
+	}
+	watcher.Start()
+	return watcher
+}
+
+func receiveChanges(t *testing.T, watcher *Watcher) []string {
+	t.Helper()
+	select {
+	case changes := <-watcher.Changes:
+		return changes
+	case err := <-watcher.Errors:
+		t.Fatalf("unexpected error from watcher: %s", err)
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timed out waiting for changes")
+	}
+	return nil
+}
+
+func expectNoChanges(t *testing.T, watcher *Watcher) {
+	t.Helper()
+	select {
+	case changes := <-watcher.Changes:
+		t.Fatalf("got changes %v but expected none", changes)
+	case <-time.After(4 * testQuietWindow):
+	}
+}
+
+func writeFile(t *testing.T, filePath, contents string) {
+	t.Helper()
+	if err := os.WriteFile(filePath, []byte(contents), 0o644); err != nil {
+		t.Fatalf("failed to write %s: %s", filePath, err)
+	}
+}
+
+func TestWatcher(t *testing.T) {
+	t.Run("should report all changes in quiet window together", func(t *testing.T) {
+		dir := t.TempDir()
+		watcher := newTestWatcher(t, dir)
+		writeFile(t, filepath.Join(dir, "b.md"), "b")
+		writeFile(t, filepath.Join(dir, "a.md"), "a")
+		writeFile(t, filepath.Join(dir, "b.md"), "bb")
+		changes := receiveChanges(t, watcher)
+		expected := []string{filepath.Join(dir, "a.md"), filepath.Join(dir, "b.md")}
+		if !reflect.DeepEqual(changes, expected) {
+			t.Errorf("got %v but expected %v", changes, expected)
+		}
+		expectNoChanges(t, watcher)
+	})
+
+	t.Run("should watch directories that are created", func(t *testing.T) {
+		dir := t.TempDir()
+		watcher := newTestWatcher(t, dir)
+		subdir := filepath.Join(dir, "posts")
+		if err := os.Mkdir(subdir, 0o755); err != nil {
+			t.Fatalf("failed to create directory: %s", err)
+		}
+		receiveChanges(t, watcher)
+		filePath := filepath.Join(subdir, "post.md")
+		writeFile(t, filePath, "post")
+		changes := receiveChanges(t, watcher)
+		expected := []string{filePath}
+		if !reflect.DeepEqual(changes, expected) {
+			t.Errorf("got %v but expected %v", changes, expected)
+		}
+	})
+
+	t.Run("should stop watching directories that are removed", func(t *testing.T) {
+		dir := t.TempDir()
+		subdir := filepath.Join(dir, "posts", "2024")
+		if err := os.MkdirAll(subdir, 0o755); err != nil {
+			t.Fatalf("failed to create directory: %s", err)
+		}
+		watcher := newTestWatcher(t, dir)
+		if err := os.RemoveAll(filepath.Join(dir, "posts")); err != nil {
+			t.Fatalf("failed to remove directory: %s", err)
+		}
+		receiveChanges(t, watcher)
+		// Watching the directories again must not fail because they are
+		// still recorded as watched.
+		if err := os.MkdirAll(subdir, 0o755); err != nil {
+			t.Fatalf("failed to create directory: %s", err)
+		}
+		receiveChanges(t, watcher)
+		filePath := filepath.Join(subdir, "post.md")
+		writeFile(t, filePath, "post")
+		changes := receiveChanges(t, watcher)
+		expected := []string{filePath}
+		if !reflect.DeepEqual(changes, expected) {
+			t.Errorf("got %v but expected %v", changes, expected)
+		}
+	})
+
+	t.Run("should ignore editor files", func(t *testing.T) {
+		dir := t.TempDir()
+		watcher := newTestWatcher(t, dir)
+		writeFile(t, filepath.Join(dir, ".post.md.swp"), "swap")
+		writeFile(t, filepath.Join(dir, "post.md~"), "backup")
+		writeFile(t, filepath.Join(dir, "4913"), "")
+		expectNoChanges(t, watcher)
+	})
+
+	t.Run("should ignore paths that SetIgnored ignores", func(t *testing.T) {
+		dir := t.TempDir()
+		watcher := newTestWatcher(t, dir)
+		watcher.SetIgnored(func(filePath string, isDir bool) bool {
+			return filepath.Ext(filePath) == ".psd" || (isDir && filepath.Base(filePath) == "drafts")
+		})
+		writeFile(t, filepath.Join(dir, "cover.psd"), "psd")
+		drafts := filepath.Join(dir, "drafts")
+		if err := os.Mkdir(drafts, 0o755); err != nil {
+			t.Fatalf("failed to create directory: %s", err)
+		}
+		writeFile(t, filepath.Join(drafts, "post.md"), "draft")
+		expectNoChanges(t, watcher)
+		filePath := filepath.Join(dir, "post.md")
+		writeFile(t, filePath, "post")
+		changes := receiveChanges(t, watcher)
+		expected := []string{filePath}
+		if !reflect.DeepEqual(changes, expected) {
+			t.Errorf("got %v but expected %v", changes, expected)
+		}
+	})
+}
+
+func TestIsEditorFile(t *testing.T) {
+	cases := map[string]bool{
+		"input/post.md":             false,
+		"input/.post.md.swp":        true,
+		"input/.post.md.swo":        true,
+		"input/post.md~":            true,
+		"input/.#post.md":           true,
+		"input/#post.md#":           true,
+		"input/4913":                true,
+		"input/post.md___jb_tmp___": true,
+		"input/swp":                 false,
+	}
+	for filePath, expected := range cases {
+		t.Run(fmt.Sprintf("should return %t for %s", expected, filePath), func(t *testing.T) {
+			if result := IsEditorFile(filePath); result != expected {
+				t.Errorf("got %t but expected %t", result, expected)
+			}
+		})
+	}
+}
+
+func TestWatcherFiles(t *testing.T) {
+	t.Run("should only report changes to watched files", func(t *testing.T) {
+		dir := t.TempDir()
+		watcher := newTestWatcher(t, filepath.Join(dir, "input"))
+		configPath := filepath.Join(dir, "configuration.yaml")
+		if err := watcher.AddFile(configPath); err != nil {
+			t.Fatalf("unexpected error in AddFile(): %s", err)
+		}
+		writeFile(t, filepath.Join(dir, "README.md"), "readme")
+		expectNoChanges(t, watcher)
+		writeFile(t, configPath, "Input: content")
+		changes := receiveChanges(t, watcher)
+		expected := []string{configPath}
+		if !reflect.DeepEqual(changes, expected) {
+			t.Errorf("got %v but expected %v", changes, expected)
+		}
+	})
+
+	t.Run("should report creation of watched directory", func(t *testing.T) {
+		dir := t.TempDir()
+		watcher := newTestWatcher(t, filepath.Join(dir, "input"))
+		dataDir := filepath.Join(dir, "data")
+		if err := watcher.AddFile(dataDir); err != nil {
+			t.Fatalf("unexpected error in AddFile(): %s", err)
+		}
+		if err := os.Mkdir(dataDir, 0o755); err != nil {
+			t.Fatalf("failed to create directory: %s", err)
+		}
+		changes := receiveChanges(t, watcher)
+		expected := []string{dataDir}
+		if !reflect.DeepEqual(changes, expected) {
+			t.Errorf("got %v but expected %v", changes, expected)
+		}
+	})
+
+	t.Run("should stop reporting changes to removed files", func(t *testing.T) {
+		dir := t.TempDir()
+		watcher := newTestWatcher(t, filepath.Join(dir, "input"))
+		configPath := filepath.Join(dir, "configuration.yaml")
+		if err := watcher.AddFile(configPath); err != nil {
+			t.Fatalf("unexpected error in AddFile(): %s", err)
+		}
+		watcher.RemoveFile(configPath)
+		writeFile(t, configPath, "Input: content")
+		expectNoChanges(t, watcher)
+	})
+}